@@ -0,0 +1,168 @@
+// SPDX-License-Identifier: Apache-2.0 OR GPL-2.0-or-later
+
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/swinslow/peridot-jobrunner-testing/fixtures"
+	"github.com/swinslow/peridot-jobrunner-testing/internal/testresult"
+	"github.com/swinslow/peridot-jobrunner-testing/test/utils"
+)
+
+// runList implements the list subcommand, which prints the name
+// of each registered test, without running any of them.
+func runList(args []string) int {
+	fs := flag.NewFlagSet("list", flag.ExitOnError)
+	tf := addTestFlags(fs)
+	runPattern := fs.String("run", "", "regular expression selecting which tests to list, matched against each test's Suite:Element:ID name")
+	fs.Parse(args)
+
+	suites, err := tf.selectTests(tf.suites(nil), "", *runPattern)
+	if err != nil {
+		fmt.Printf("Error selecting tests: %v\n", err)
+		return 2
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 8, 4, 1, ' ', 0)
+	for _, s := range suites {
+		for _, t := range s.Tests {
+			res, _ := utils.DryRunTest(t.Func, "")
+			fmt.Fprintf(w, "%s\t%s\t%s\t%s\n", res.Suite, res.Element, res.ID, strings.Join(t.Tags, ","))
+		}
+	}
+	w.Flush()
+	fmt.Printf("\n%d tests\n", countTests(suites))
+	return 0
+}
+
+// runExplain implements the explain subcommand, which prints the
+// steps that each test matching a pattern would perform, found
+// with a dry run of the test.
+func runExplain(args []string) int {
+	fs := flag.NewFlagSet("explain", flag.ExitOnError)
+	tf := addTestFlags(fs)
+	fs.Usage = func() {
+		fmt.Fprintf(fs.Output(), "Usage: peridot-jobrunner-testing explain [flags] <pattern>\n\n")
+		fmt.Fprintf(fs.Output(), "Prints the steps of each test whose Suite:Element:ID name matches the regular expression pattern.\n\n")
+		fs.PrintDefaults()
+	}
+	fs.Parse(args)
+	if fs.NArg() != 1 {
+		fs.Usage()
+		return 2
+	}
+
+	suites, err := tf.selectTests(tf.suites(nil), "", fs.Arg(0))
+	if err != nil {
+		fmt.Printf("Error selecting tests: %v\n", err)
+		return 2
+	}
+	if len(suites) == 0 {
+		fmt.Printf("No tests match %q\n", fs.Arg(0))
+		return 1
+	}
+
+	for _, s := range suites {
+		for _, t := range s.Tests {
+			explainTest(s, t)
+		}
+	}
+	return 0
+}
+
+// explainTest prints the steps that t, a test in suite s, would
+// perform, found with a dry run. Hooks are not dry run, so it
+// only notes which of the suite's hooks run around t.
+func explainTest(s testresult.Suite, t testresult.Test) {
+	res, steps := utils.DryRunTest(t.Func, "")
+	fmt.Printf("%s\n", res.Name())
+	if len(t.Tags) > 0 {
+		fmt.Printf("  tags: %s\n", strings.Join(t.Tags, ", "))
+	}
+	if hooks := hookNames(s.Hooks); len(hooks) > 0 {
+		fmt.Printf("  %s hooks: %s\n", s.Name, strings.Join(hooks, ", "))
+	}
+	for _, st := range steps {
+		fmt.Printf("  step %s:\t%s\n", st.Step, st.Description)
+	}
+	if !res.Success {
+		fmt.Printf("  (later steps depend on the API's responses; the dry run stopped at step %s: %v)\n", res.FailStep, res.FailError)
+	}
+	fmt.Printf("\n")
+}
+
+// hookNames returns the names of the hooks that are set in h.
+func hookNames(h testresult.Hooks) []string {
+	names := []string{}
+	if h.BeforeAll != nil {
+		names = append(names, "BeforeAll")
+	}
+	if h.BeforeEach != nil {
+		names = append(names, "BeforeEach")
+	}
+	if h.AfterEach != nil {
+		names = append(names, "AfterEach")
+	}
+	if h.AfterAll != nil {
+		names = append(names, "AfterAll")
+	}
+	return names
+}
+
+// runFixtures implements the fixtures subcommand, which prepares
+// the DB and volumes for manual debugging without running any
+// tests. "setup" puts them in the fixture state that each test
+// starts from; "reset" empties them.
+func runFixtures(args []string) int {
+	fs := flag.NewFlagSet("fixtures", flag.ExitOnError)
+	ef := addEnvFlags(fs)
+	fs.Usage = func() {
+		fmt.Fprintf(fs.Output(), "Usage: peridot-jobrunner-testing fixtures [flags] setup|reset\n\n")
+		fs.PrintDefaults()
+	}
+	fs.Parse(args)
+	if fs.NArg() != 1 {
+		fs.Usage()
+		return 2
+	}
+
+	volumes := ef.volumes()
+	switch fs.Arg(0) {
+	case "setup":
+		err := fixtures.ResetDB(*ef.apiRoot)
+		if err == nil {
+			err = fixtures.SetupFixture(*ef.apiRoot)
+		}
+		if err != nil {
+			fmt.Printf("Error setting up DB fixture: %v\n", err)
+			return 1
+		}
+		err = volumes.Reset()
+		if err != nil {
+			fmt.Printf("Error setting up volumes: %v\n", err)
+			return 1
+		}
+		fmt.Printf("DB and volumes are in the fixture state.\n")
+	case "reset":
+		err := fixtures.ResetDB(*ef.apiRoot)
+		if err != nil {
+			fmt.Printf("Error resetting DB: %v\n", err)
+			return 1
+		}
+		err = volumes.Clear()
+		if err != nil {
+			fmt.Printf("Error clearing volumes: %v\n", err)
+			return 1
+		}
+		fmt.Printf("DB and volumes are empty.\n")
+	default:
+		fs.Usage()
+		return 2
+	}
+	return 0
+}
@@ -0,0 +1,87 @@
+// SPDX-License-Identifier: Apache-2.0 OR GPL-2.0-or-later
+
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/swinslow/peridot-jobrunner-testing/internal/testresult"
+	"github.com/swinslow/peridot-jobrunner-testing/test/utils"
+)
+
+// pauseAfterFailure prints what is needed to inspect the state
+// that a failing test left behind, and waits for a line of input
+// before the test's AfterEach hook, any rerun, or the next test
+// changes it. It returns true if the user asked to stop running
+// tests, or if in has no more input.
+func pauseAfterFailure(res *testresult.TestResult, root string, codeDir string, spdxDir string, in *bufio.Reader) bool {
+	fmt.Printf("\n========== PAUSED after failing test\n\n")
+	fmt.Printf("%s\n", res.Name())
+	fmt.Printf("    Step:   %s\n", res.FailStep)
+	fmt.Printf("    Errors: %v\n", res.FailError)
+	if diff := utils.MatchDiff(res); diff != "" {
+		fmt.Printf("    Diff:\n")
+		for _, line := range strings.Split(strings.TrimRight(diff, "\n"), "\n") {
+			fmt.Printf("      %s\n", line)
+		}
+	}
+
+	fmt.Printf("\nThe DB and volumes have been left as the test left them.\n")
+	fmt.Printf("    API:  %s\n", root)
+	fmt.Printf("    code: %s\n", codeDir)
+	fmt.Printf("    spdx: %s\n", spdxDir)
+
+	fmt.Printf("\nAuth tokens:\n")
+	for _, user := range utils.Users {
+		fmt.Printf("    %-10s %s\n", user, utils.Token(user))
+	}
+
+	if len(res.Exchanges) > 0 {
+		fmt.Printf("\nRequests made by the test:\n")
+		for _, ex := range res.Exchanges {
+			fmt.Printf("    step %s: %s\n", ex.Step, curlCommand(ex))
+		}
+	}
+
+	fmt.Printf("\nPress Enter to reset and continue, or type q and Enter to stop: ")
+	line, err := in.ReadString('\n')
+	if err == io.EOF && line == "" {
+		fmt.Printf("\nNo more input; stopping.\n")
+		return true
+	}
+	fmt.Printf("\n")
+	return strings.TrimSpace(line) == "q"
+}
+
+// isTerminal returns whether f is a terminal, so that
+// pauseAfterFailure can read input from it. /dev/null, which is
+// what docker-compose up gives as stdin, is a character device
+// too, so it is checked for separately.
+func isTerminal(f *os.File) bool {
+	fi, err := f.Stat()
+	if err != nil || fi.Mode()&os.ModeCharDevice == 0 {
+		return false
+	}
+	null, err := os.Stat(os.DevNull)
+	return err != nil || !os.SameFile(fi, null)
+}
+
+// curlCommand returns a curl command line that repeats ex.
+func curlCommand(ex testresult.Exchange) string {
+	cmd := "curl -i"
+	if ex.Method != "GET" {
+		cmd += " -X " + ex.Method
+	}
+	if token := utils.Token(ex.User); token != "" {
+		cmd += fmt.Sprintf(" -H 'Authorization: Bearer %s'", token)
+	}
+	if ex.RequestBody != "" {
+		body := strings.Join(strings.Fields(ex.RequestBody), " ")
+		cmd += " -d '" + strings.Replace(body, "'", `'\''`, -1) + "'"
+	}
+	return cmd + " '" + ex.URL + "'"
+}
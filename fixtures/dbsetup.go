@@ -5,7 +5,10 @@ package fixtures
 import (
 	"fmt"
 	"io/ioutil"
+	"net"
 	"net/http"
+	"net/url"
+	"strconv"
 	"strings"
 
 	"github.com/swinslow/peridot-jobrunner-testing/test/utils"
@@ -16,7 +19,7 @@ import (
 // will be set.
 func ResetDB(root string) error {
 	resetCommand := `{"command": "resetDB"}`
-	client := &http.Client{}
+	client := utils.Client()
 	req, err := http.NewRequest("POST", root+"/admin/db", strings.NewReader(resetCommand))
 	if err != nil {
 		return fmt.Errorf("got error from resetDB http request creator: %s", err)
@@ -200,23 +203,42 @@ func createRepoPulls(root string) error {
 	return nil
 }
 
-func createAgents(root string) error {
-	url := root + "/agents"
+// agentFixture is an agent that is registered by SetupFixture.
+type agentFixture struct {
+	name         string
+	isActive     bool
+	address      string
+	port         int
+	isCodeReader bool
+	isSpdxReader bool
+	isCodeWriter bool
+	isSpdxWriter bool
+}
 
-	calls := []struct {
-		name         string
-		isActive     bool
-		address      string
-		port         int
-		isCodeReader bool
-		isSpdxReader bool
-		isCodeWriter bool
-		isSpdxWriter bool
-	}{
-		{"nop", true, "https://agent-nop", 3010, false, false, false, false},
+// agentFixtures are the agents that are registered by
+// SetupFixture.
+var agentFixtures = []agentFixture{
+	{"nop", true, "https://agent-nop", 3010, false, false, false, false},
+}
+
+// AgentAddrs returns the host:port gRPC address of each agent
+// that is registered by SetupFixture, keyed by agent name.
+func AgentAddrs() map[string]string {
+	addrs := map[string]string{}
+	for _, c := range agentFixtures {
+		host := c.address
+		if u, err := url.Parse(c.address); err == nil && u.Host != "" {
+			host = u.Hostname()
+		}
+		addrs[c.name] = net.JoinHostPort(host, strconv.Itoa(c.port))
 	}
+	return addrs
+}
 
-	for _, c := range calls {
+func createAgents(root string) error {
+	url := root + "/agents"
+
+	for _, c := range agentFixtures {
 		body := fmt.Sprintf(`{"name":"%s", "is_active":%t, "address":"%s", "port":%d, "is_codereader":%t, "is_spdxreader":%t, "is_codewriter":%t, "is_spdxwriter":%t}`, c.name, c.isActive, c.address, c.port, c.isCodeReader, c.isSpdxReader, c.isCodeWriter, c.isSpdxWriter)
 		err := utils.PostNoRes(url, body, 201, "operator")
 		if err != nil {
@@ -0,0 +1,202 @@
+// SPDX-License-Identifier: Apache-2.0 OR GPL-2.0-or-later
+
+package fixtures
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"os"
+	"os/exec"
+	"strconv"
+	"time"
+)
+
+// PGConfig holds the connection details for the postgres
+// database behind the peridot API, for use with the pg_dump
+// and psql command-line tools.
+type PGConfig struct {
+	Host     string
+	Port     int
+	User     string
+	Password string
+	DBName   string
+}
+
+// args returns the connection arguments shared by pg_dump
+// and psql.
+func (cfg *PGConfig) args() []string {
+	return []string{
+		"-h", cfg.Host,
+		"-p", strconv.Itoa(cfg.Port),
+		"-U", cfg.User,
+		"-d", cfg.DBName,
+	}
+}
+
+// command creates an exec.Cmd for the named postgres tool,
+// with the connection arguments and password filled in.
+func (cfg *PGConfig) command(name string, extra ...string) *exec.Cmd {
+	args := append(cfg.args(), extra...)
+	cmd := exec.Command(name, args...)
+	cmd.Env = os.Environ()
+	if cfg.Password != "" {
+		cmd.Env = append(cmd.Env, "PGPASSWORD="+cfg.Password)
+	}
+	return cmd
+}
+
+// runPGCommand runs cmd and, on failure, includes its stderr
+// output in the returned error.
+func runPGCommand(cmd *exec.Cmd) error {
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	err := cmd.Run()
+	if err != nil {
+		return fmt.Errorf("%s failed: %v: %s", cmd.Path, err, stderr.String())
+	}
+	return nil
+}
+
+// TakeSnapshot runs pg_dump against the database described by
+// cfg, and writes the dump to the file at dest. The dump drops
+// and recreates each table, so that it can later be passed to
+// RestoreSnapshot regardless of the database's state.
+func TakeSnapshot(cfg *PGConfig, dest string) error {
+	cmd := cfg.command("pg_dump", "--clean", "--if-exists", "--no-owner", "-f", dest)
+	return runPGCommand(cmd)
+}
+
+// RestoreSnapshot loads a dump created by TakeSnapshot back
+// into the database described by cfg, in a single transaction.
+func RestoreSnapshot(cfg *PGConfig, src string) error {
+	cmd := cfg.command("psql", "-q", "-X", "-1", "-v", "ON_ERROR_STOP=1", "-f", src)
+	return runPGCommand(cmd)
+}
+
+// PGReady runs pg_isready against the database described by
+// cfg, and returns nil if it is accepting connections. If
+// pg_isready is not installed, it only checks that the port is
+// open.
+func PGReady(cfg *PGConfig) error {
+	if _, err := exec.LookPath("pg_isready"); err != nil {
+		addr := net.JoinHostPort(cfg.Host, strconv.Itoa(cfg.Port))
+		conn, err := net.DialTimeout("tcp", addr, 2*time.Second)
+		if err != nil {
+			return err
+		}
+		return conn.Close()
+	}
+	return runPGCommand(cfg.command("pg_isready", "-t", "2"))
+}
+
+// DBFixture resets the database to the fixture state before
+// each test. The first call to Reset builds the fixture state
+// through the API and snapshots it; later calls restore that
+// snapshot directly into postgres. If snapshots are disabled or
+// fail (e.g. pg_dump is not installed, or is older than the
+// server), it warns and falls back to rebuilding the fixtures
+// through the API each time; FallbackReason then says why.
+type DBFixture struct {
+	// Root is the API root URL.
+	Root string
+
+	// PG holds the database connection details. If nil,
+	// snapshots are disabled.
+	PG *PGConfig
+
+	snapshotPath string
+	useAPI       bool
+
+	// fallbackErr is why snapshots were disabled, if they were
+	// enabled to begin with.
+	fallbackErr error
+}
+
+// NewDBFixture creates a DBFixture for the API at root. If pg
+// is nil, the fixture is always rebuilt through the API.
+func NewDBFixture(root string, pg *PGConfig) *DBFixture {
+	return &DBFixture{
+		Root:   root,
+		PG:     pg,
+		useAPI: pg == nil,
+	}
+}
+
+// Reset returns the database to the fixture state.
+func (f *DBFixture) Reset() error {
+	if f.useAPI {
+		return f.resetViaAPI()
+	}
+
+	if f.snapshotPath != "" {
+		err := RestoreSnapshot(f.PG, f.snapshotPath)
+		if err == nil {
+			return nil
+		}
+		f.disableSnapshots(fmt.Errorf("error restoring DB snapshot: %v", err))
+		return f.resetViaAPI()
+	}
+
+	// no snapshot yet, so build the fixture and take one
+	err := f.resetViaAPI()
+	if err != nil {
+		return err
+	}
+	tmp, err := ioutil.TempFile("", "peridot-fixture-*.sql")
+	if err != nil {
+		f.disableSnapshots(fmt.Errorf("error creating DB snapshot file: %v", err))
+		return nil
+	}
+	tmp.Close()
+	f.snapshotPath = tmp.Name()
+	err = TakeSnapshot(f.PG, f.snapshotPath)
+	if err != nil {
+		f.disableSnapshots(fmt.Errorf("error taking DB snapshot: %v", err))
+	}
+
+	return nil
+}
+
+// Close removes the snapshot file, if any.
+func (f *DBFixture) Close() error {
+	if f.snapshotPath == "" {
+		return nil
+	}
+	err := os.Remove(f.snapshotPath)
+	f.snapshotPath = ""
+	return err
+}
+
+// disableSnapshots switches to rebuilding through the API for
+// all future calls to Reset, because of err, and warns about it.
+func (f *DBFixture) disableSnapshots(err error) {
+	f.Close()
+	f.useAPI = true
+	f.fallbackErr = err
+	fmt.Printf("\n********************\n")
+	fmt.Printf("WARNING: DB snapshots are disabled; every remaining DB reset will be rebuilt through the API, which is much slower.\n")
+	fmt.Printf("  %v\n", err)
+	fmt.Printf("********************\n\n")
+}
+
+// FallbackReason returns why snapshots were disabled after
+// being enabled, or nil if they were not.
+func (f *DBFixture) FallbackReason() error {
+	return f.fallbackErr
+}
+
+// resetViaAPI resets the database and rebuilds the fixtures
+// with calls to the peridot API.
+func (f *DBFixture) resetViaAPI() error {
+	err := ResetDB(f.Root)
+	if err != nil {
+		return fmt.Errorf("error resetting DB: %v", err)
+	}
+	err = SetupFixture(f.Root)
+	if err != nil {
+		return fmt.Errorf("error setting fixtures: %v", err)
+	}
+	return nil
+}
@@ -0,0 +1,8 @@
+// SPDX-License-Identifier: Apache-2.0
+
+#include <stdio.h>
+
+int main(void) {
+	printf("hello, world\n");
+	return 0;
+}
@@ -0,0 +1,4 @@
+# SPDX-License-Identifier: MIT
+
+def greet(name):
+    return "hello, " + name
@@ -3,30 +3,109 @@
 package fixtures
 
 import (
-	"io/ioutil"
-	"os"
-	"path"
+	"fmt"
+	"strings"
+
+	"github.com/swinslow/peridot-jobrunner-testing/internal/fstree"
 )
 
-// ResetVolume clears the /code and /spdx directories.
-func ResetVolume() error {
-	// delete contents of /code directory but not /code itself
-	contents, err := ioutil.ReadDir("/code")
-	if err != nil {
-		return err
+// Volume describes one directory that is shared with the
+// agents, such as /code or /spdx, together with the fixture
+// tree that it is seeded from.
+type Volume struct {
+	// Name identifies the volume, e.g. "code".
+	Name string
+
+	// Root is the directory whose contents are managed.
+	Root string
+
+	// Seed is a directory whose contents are copied into Root
+	// on each reset. If empty, Root is left empty.
+	Seed string
+}
+
+// VolumeManager clears, seeds and verifies the volumes that
+// are shared with the agents.
+type VolumeManager struct {
+	Volumes []*Volume
+}
+
+// NewVolumeManager creates a VolumeManager for the given volumes.
+func NewVolumeManager(vols ...*Volume) *VolumeManager {
+	return &VolumeManager{Volumes: vols}
+}
+
+// Root returns the root directory for the named volume, or
+// the empty string if there is no volume with that name.
+func (vm *VolumeManager) Root(name string) string {
+	for _, v := range vm.Volumes {
+		if v.Name == name {
+			return v.Root
+		}
 	}
-	for _, c := range contents {
-		os.RemoveAll(path.Join([]string{"code", c.Name()}...))
+	return ""
+}
+
+// Clear deletes the contents of each volume, but not the
+// volume directories themselves.
+func (vm *VolumeManager) Clear() error {
+	for _, v := range vm.Volumes {
+		err := fstree.Clear(v.Root)
+		if err != nil {
+			return fmt.Errorf("error clearing %s volume: %v", v.Name, err)
+		}
 	}
+	return nil
+}
 
-	// delete contents of /spdx directory but not /spdx itself
-	contents, err = ioutil.ReadDir("/spdx")
+// Seed copies each volume's fixture tree into it.
+func (vm *VolumeManager) Seed() error {
+	for _, v := range vm.Volumes {
+		if v.Seed == "" {
+			continue
+		}
+		err := fstree.Copy(v.Seed, v.Root)
+		if err != nil {
+			return fmt.Errorf("error seeding %s volume: %v", v.Name, err)
+		}
+	}
+	return nil
+}
+
+// Verify checks that each volume contains exactly the files
+// from its fixture tree, or nothing if it has no fixture tree.
+func (vm *VolumeManager) Verify() error {
+	for _, v := range vm.Volumes {
+		var diffs []string
+		var err error
+		if v.Seed == "" {
+			diffs, err = fstree.Files(v.Root)
+			for i := range diffs {
+				diffs[i] = "unexpected: " + diffs[i]
+			}
+		} else {
+			diffs, err = fstree.Diff(v.Root, v.Seed)
+		}
+		if err != nil {
+			return fmt.Errorf("error verifying %s volume: %v", v.Name, err)
+		}
+		if len(diffs) > 0 {
+			return fmt.Errorf("%s volume does not match fixture: %s", v.Name, strings.Join(diffs, ", "))
+		}
+	}
+	return nil
+}
+
+// Reset clears and re-seeds each volume, and then verifies
+// that it is in the expected state.
+func (vm *VolumeManager) Reset() error {
+	err := vm.Clear()
 	if err != nil {
 		return err
 	}
-	for _, c := range contents {
-		os.RemoveAll(path.Join([]string{"spdx", c.Name()}...))
+	err = vm.Seed()
+	if err != nil {
+		return err
 	}
-
-	return nil
+	return vm.Verify()
 }
@@ -3,7 +3,7 @@ module github.com/swinslow/peridot-jobrunner-testing
 go 1.13
 
 require (
-	github.com/sergi/go-diff v1.0.0 // indirect
+	github.com/sergi/go-diff v1.0.0
 	github.com/yudai/gojsondiff v1.0.0
 	github.com/yudai/golcs v0.0.0-20170316035057-ecda9a501e82 // indirect
 )
@@ -0,0 +1,100 @@
+// SPDX-License-Identifier: Apache-2.0 OR GPL-2.0-or-later
+
+// Package baseline saves the results of a run to a file, and
+// compares a later run against it to report what changed.
+package baseline
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"time"
+
+	"github.com/swinslow/peridot-jobrunner-testing/internal/preflight"
+	"github.com/swinslow/peridot-jobrunner-testing/internal/testresult"
+)
+
+// Exchange is the saved form of one HTTP request and response.
+type Exchange struct {
+	Method       string `json:"method"`
+	URL          string `json:"url"`
+	User         string `json:"user"`
+	RequestBody  string `json:"request_body,omitempty"`
+	StatusCode   int    `json:"status_code"`
+	ResponseBody string `json:"response_body,omitempty"`
+}
+
+// Result is the saved form of one test's result.
+type Result struct {
+	Name      string      `json:"name"`
+	Success   bool        `json:"success"`
+	Exchanges []*Exchange `json:"exchanges,omitempty"`
+}
+
+// HookResult is the saved form of one hook that failed.
+type HookResult struct {
+	Name    string `json:"name"`
+	Error   string `json:"error"`
+	Skipped int    `json:"skipped,omitempty"`
+}
+
+// Baseline holds the saved results of one run.
+type Baseline struct {
+	Created time.Time `json:"created"`
+
+	// Environment describes the services that were tested,
+	// if known.
+	Environment *preflight.Environment `json:"environment,omitempty"`
+
+	Results []*Result `json:"results"`
+
+	// Hooks holds each hook that failed.
+	Hooks []*HookResult `json:"hooks,omitempty"`
+}
+
+// FromResults creates a Baseline from a run's results, its
+// failed hooks, and the environment that they were run in, which
+// may be nil.
+func FromResults(rs []*testresult.TestResult, hooks []*testresult.HookResult, env *preflight.Environment, at time.Time) *Baseline {
+	b := &Baseline{Created: at, Environment: env}
+	for _, h := range hooks {
+		b.Hooks = append(b.Hooks, &HookResult{Name: h.Name(), Error: h.Err.Error(), Skipped: h.Skipped})
+	}
+	for _, r := range rs {
+		br := &Result{Name: r.Name(), Success: r.Success}
+		for _, ex := range r.Exchanges {
+			br.Exchanges = append(br.Exchanges, &Exchange{
+				Method:       ex.Method,
+				URL:          ex.URL,
+				User:         ex.User,
+				RequestBody:  ex.RequestBody,
+				StatusCode:   ex.StatusCode,
+				ResponseBody: string(ex.ResponseBody),
+			})
+		}
+		b.Results = append(b.Results, br)
+	}
+	return b
+}
+
+// Load reads a Baseline from the file at path.
+func Load(path string) (*Baseline, error) {
+	js, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	b := &Baseline{}
+	err = json.Unmarshal(js, b)
+	if err != nil {
+		return nil, err
+	}
+	return b, nil
+}
+
+// Save writes the Baseline to the file at path.
+func (b *Baseline) Save(path string) error {
+	js, err := json.MarshalIndent(b, "", "  ")
+	if err != nil {
+		return err
+	}
+	return ioutil.WriteFile(path, js, 0644)
+}
@@ -0,0 +1,257 @@
+// SPDX-License-Identifier: Apache-2.0 OR GPL-2.0-or-later
+
+package baseline
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/swinslow/peridot-jobrunner-testing/internal/preflight"
+	"github.com/yudai/gojsondiff"
+	"github.com/yudai/gojsondiff/formatter"
+)
+
+// ResponseChange describes a request that was made in both
+// runs, but that got a different response.
+type ResponseChange struct {
+	// Test is the name of the test that made the request.
+	Test string
+
+	// Request describes the request, e.g. "GET /jobs/4 (viewer)".
+	Request string
+
+	// OldCode and NewCode are the HTTP status codes.
+	OldCode int
+	NewCode int
+
+	// Diff describes how the response body changed.
+	Diff string
+}
+
+// Comparison holds the differences between two runs.
+type Comparison struct {
+	// Environment describes each service whose version or
+	// health changed.
+	Environment []string
+
+	NewlyFailing []string
+	NewlyPassing []string
+	Added        []string
+	Removed      []string
+	Changed      []*ResponseChange
+
+	// NewlyFailingHooks and FixedHooks name the hooks that
+	// failed in only the new run, or in only the old one.
+	NewlyFailingHooks []string
+	FixedHooks        []string
+}
+
+// Empty returns whether nothing changed between the runs.
+func (c *Comparison) Empty() bool {
+	return len(c.Environment) == 0 && len(c.NewlyFailing) == 0 && len(c.NewlyPassing) == 0 &&
+		len(c.Added) == 0 && len(c.Removed) == 0 && len(c.Changed) == 0 &&
+		len(c.NewlyFailingHooks) == 0 && len(c.FixedHooks) == 0
+}
+
+// Compare compares the results of a new run against an old one.
+func Compare(old *Baseline, cur *Baseline) *Comparison {
+	c := &Comparison{Environment: preflight.Diff(old.Environment, cur.Environment)}
+
+	oldByName := map[string]*Result{}
+	for _, r := range old.Results {
+		oldByName[r.Name] = r
+	}
+	curByName := map[string]*Result{}
+	for _, r := range cur.Results {
+		curByName[r.Name] = r
+	}
+
+	for _, r := range cur.Results {
+		o, ok := oldByName[r.Name]
+		if !ok {
+			c.Added = append(c.Added, r.Name)
+			continue
+		}
+		if o.Success && !r.Success {
+			c.NewlyFailing = append(c.NewlyFailing, r.Name)
+		}
+		if !o.Success && r.Success {
+			c.NewlyPassing = append(c.NewlyPassing, r.Name)
+		}
+		c.Changed = append(c.Changed, compareExchanges(r.Name, o.Exchanges, r.Exchanges)...)
+	}
+	for _, r := range old.Results {
+		if _, ok := curByName[r.Name]; !ok {
+			c.Removed = append(c.Removed, r.Name)
+		}
+	}
+
+	oldHooks := map[string]bool{}
+	for _, h := range old.Hooks {
+		oldHooks[h.Name] = true
+	}
+	curHooks := map[string]bool{}
+	for _, h := range cur.Hooks {
+		curHooks[h.Name] = true
+		if !oldHooks[h.Name] {
+			c.NewlyFailingHooks = append(c.NewlyFailingHooks, h.Name)
+		}
+	}
+	for _, h := range old.Hooks {
+		if !curHooks[h.Name] {
+			c.FixedHooks = append(c.FixedHooks, h.Name)
+		}
+	}
+
+	sort.Strings(c.NewlyFailing)
+	sort.Strings(c.NewlyPassing)
+	sort.Strings(c.NewlyFailingHooks)
+	sort.Strings(c.FixedHooks)
+	sort.Strings(c.Added)
+	sort.Strings(c.Removed)
+	return c
+}
+
+// requestKey identifies a request by everything that was sent.
+func requestKey(ex *Exchange) string {
+	return ex.Method + " " + ex.URL + " " + ex.User + " " + ex.RequestBody
+}
+
+// describe returns a short description of a request.
+func describe(ex *Exchange) string {
+	return fmt.Sprintf("%s %s (%s)", ex.Method, ex.URL, ex.User)
+}
+
+// compareExchanges pairs up identical requests made by the same
+// test in each run, in order, and reports those whose responses
+// differ. A request that was made more times in one run than the
+// other is only compared as many times as it was made in both.
+func compareExchanges(test string, old []*Exchange, cur []*Exchange) []*ResponseChange {
+	oldByKey := map[string][]*Exchange{}
+	for _, ex := range old {
+		k := requestKey(ex)
+		oldByKey[k] = append(oldByKey[k], ex)
+	}
+
+	changes := []*ResponseChange{}
+	for _, ex := range cur {
+		k := requestKey(ex)
+		if len(oldByKey[k]) == 0 {
+			continue
+		}
+		o := oldByKey[k][0]
+		oldByKey[k] = oldByKey[k][1:]
+
+		diff, same := diffBodies(o.ResponseBody, ex.ResponseBody)
+		if same && o.StatusCode == ex.StatusCode {
+			continue
+		}
+		changes = append(changes, &ResponseChange{
+			Test:    test,
+			Request: describe(ex),
+			OldCode: o.StatusCode,
+			NewCode: ex.StatusCode,
+			Diff:    diff,
+		})
+	}
+	return changes
+}
+
+// diffBodies compares two response bodies, as JSON if they both
+// parse as JSON, and otherwise as text. Timestamps in JSON bodies
+// are masked first, since they differ between any two runs. It
+// returns a description of the difference and whether they were
+// equivalent.
+func diffBodies(old string, cur string) (string, bool) {
+	if old == cur {
+		return "", true
+	}
+
+	var left, right interface{}
+	if json.Unmarshal([]byte(old), &left) == nil && json.Unmarshal([]byte(cur), &right) == nil {
+		lb, _ := json.Marshal(maskTimes(left))
+		rb, _ := json.Marshal(maskTimes(right))
+		d, err := gojsondiff.New().Compare(lb, rb)
+		if err == nil {
+			if !d.Modified() {
+				return "", true
+			}
+			f := formatter.NewAsciiFormatter(maskTimes(left), formatter.AsciiFormatterConfig{})
+			s, err := f.Format(d)
+			if err == nil {
+				return s, false
+			}
+		}
+	}
+
+	return fmt.Sprintf("-%s\n+%s\n", old, cur), false
+}
+
+// maskedTime replaces non-zero timestamps in JSON bodies.
+const maskedTime = "<time>"
+
+// maskTimes replaces every string in v that is a non-zero RFC 3339
+// timestamp with maskedTime. Zero timestamps are left alone, so
+// that a job starting or finishing is still reported as a change.
+func maskTimes(v interface{}) interface{} {
+	switch vv := v.(type) {
+	case map[string]interface{}:
+		for k, e := range vv {
+			vv[k] = maskTimes(e)
+		}
+	case []interface{}:
+		for i, e := range vv {
+			vv[i] = maskTimes(e)
+		}
+	case string:
+		t, err := time.Parse(time.RFC3339Nano, vv)
+		if err == nil && !t.IsZero() {
+			return maskedTime
+		}
+	}
+	return v
+}
+
+// Print writes the comparison to w.
+func (c *Comparison) Print(w io.Writer) {
+	if c.Empty() {
+		fmt.Fprintf(w, "No changes from baseline.\n")
+		return
+	}
+
+	printList := func(title string, names []string) {
+		if len(names) == 0 {
+			return
+		}
+		fmt.Fprintf(w, "%s (%d):\n", title, len(names))
+		for _, n := range names {
+			fmt.Fprintf(w, "  %s\n", n)
+		}
+	}
+	printList("Environment changes", c.Environment)
+	printList("Newly failing", c.NewlyFailing)
+	printList("Newly passing", c.NewlyPassing)
+	printList("Newly failing hooks", c.NewlyFailingHooks)
+	printList("Fixed hooks", c.FixedHooks)
+	printList("Added", c.Added)
+	printList("Removed", c.Removed)
+
+	if len(c.Changed) > 0 {
+		fmt.Fprintf(w, "Changed responses (%d):\n", len(c.Changed))
+		for _, ch := range c.Changed {
+			fmt.Fprintf(w, "  %s: %s\n", ch.Test, ch.Request)
+			if ch.OldCode != ch.NewCode {
+				fmt.Fprintf(w, "    status: %d -> %d\n", ch.OldCode, ch.NewCode)
+			}
+			for _, line := range strings.Split(strings.TrimRight(ch.Diff, "\n"), "\n") {
+				if line != "" {
+					fmt.Fprintf(w, "    %s\n", line)
+				}
+			}
+		}
+	}
+}
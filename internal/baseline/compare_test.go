@@ -0,0 +1,207 @@
+// SPDX-License-Identifier: Apache-2.0 OR GPL-2.0-or-later
+
+package baseline
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// get returns a GET exchange made as viewer.
+func get(url string, code int, body string) *Exchange {
+	return &Exchange{Method: "GET", URL: url, User: "viewer", StatusCode: code, ResponseBody: body}
+}
+
+func TestCompareResults(t *testing.T) {
+	old := &Baseline{Results: []*Result{
+		{Name: "s:e:still passing", Success: true},
+		{Name: "s:e:breaks", Success: true},
+		{Name: "s:e:fixed", Success: false},
+		{Name: "s:e:still failing", Success: false},
+		{Name: "s:e:removed", Success: true},
+	}}
+	cur := &Baseline{Results: []*Result{
+		{Name: "s:e:still passing", Success: true},
+		{Name: "s:e:breaks", Success: false},
+		{Name: "s:e:fixed", Success: true},
+		{Name: "s:e:still failing", Success: false},
+		{Name: "s:e:b added", Success: false},
+		{Name: "s:e:a added", Success: true},
+	}}
+
+	c := Compare(old, cur)
+	if want := []string{"s:e:breaks"}; !reflect.DeepEqual(c.NewlyFailing, want) {
+		t.Errorf("got newly failing %v, wanted %v", c.NewlyFailing, want)
+	}
+	if want := []string{"s:e:fixed"}; !reflect.DeepEqual(c.NewlyPassing, want) {
+		t.Errorf("got newly passing %v, wanted %v", c.NewlyPassing, want)
+	}
+	if want := []string{"s:e:a added", "s:e:b added"}; !reflect.DeepEqual(c.Added, want) {
+		t.Errorf("got added %v, wanted %v", c.Added, want)
+	}
+	if want := []string{"s:e:removed"}; !reflect.DeepEqual(c.Removed, want) {
+		t.Errorf("got removed %v, wanted %v", c.Removed, want)
+	}
+	if len(c.Changed) != 0 || len(c.Environment) != 0 {
+		t.Errorf("got changed %v and environment %v, wanted neither", c.Changed, c.Environment)
+	}
+	if c.Empty() {
+		t.Errorf("got empty comparison")
+	}
+}
+
+func TestCompareHooks(t *testing.T) {
+	old := &Baseline{Hooks: []*HookResult{
+		{Name: "scheduling:BeforeAll", Error: "no agent"},
+		{Name: "agents:AfterEach (jobrunner:nop:run (operator))", Error: "timeout"},
+	}}
+	cur := &Baseline{Hooks: []*HookResult{
+		{Name: "agents:AfterEach (jobrunner:nop:run (operator))", Error: "a different error"},
+		{Name: "fuzz:fixture reset (fuzz:jobs:POST)", Error: "connection refused"},
+	}}
+
+	c := Compare(old, cur)
+	if want := []string{"fuzz:fixture reset (fuzz:jobs:POST)"}; !reflect.DeepEqual(c.NewlyFailingHooks, want) {
+		t.Errorf("got newly failing hooks %v, wanted %v", c.NewlyFailingHooks, want)
+	}
+	if want := []string{"scheduling:BeforeAll"}; !reflect.DeepEqual(c.FixedHooks, want) {
+		t.Errorf("got fixed hooks %v, wanted %v", c.FixedHooks, want)
+	}
+
+	// a hook that fails in both runs is not a change
+	if c := Compare(old, old); !c.Empty() {
+		t.Errorf("comparing a run with itself: got %+v, wanted no changes", c)
+	}
+}
+
+func TestCompareExchangesPairsInOrder(t *testing.T) {
+	old := []*Exchange{
+		get("/jobs/5", 200, `{"status": "startup"}`),
+		get("/jobs/6", 200, `{"status": "startup"}`),
+		get("/jobs/5", 200, `{"status": "running"}`),
+	}
+	cur := []*Exchange{
+		get("/jobs/5", 200, `{"status": "startup"}`),
+		get("/jobs/5", 200, `{"status": "stopped"}`),
+		get("/jobs/6", 200, `{"status": "startup"}`),
+		// made once more than in the old run, so not compared
+		get("/jobs/5", 500, ``),
+	}
+
+	changes := compareExchanges("s:e:t", old, cur)
+	if len(changes) != 1 {
+		t.Fatalf("got %d changes %+v, wanted 1", len(changes), changes)
+	}
+	ch := changes[0]
+	if ch.Test != "s:e:t" || ch.Request != "GET /jobs/5 (viewer)" || ch.OldCode != 200 || ch.NewCode != 200 {
+		t.Errorf("got change %+v, wanted the second GET /jobs/5", ch)
+	}
+	if !strings.Contains(ch.Diff, "running") || !strings.Contains(ch.Diff, "stopped") {
+		t.Errorf("got diff %q, wanted running -> stopped", ch.Diff)
+	}
+}
+
+func TestCompareExchangesKeys(t *testing.T) {
+	post := func(user string, body string, code int) *Exchange {
+		return &Exchange{Method: "POST", URL: "/repopulls/3/jobs", User: user, RequestBody: body, StatusCode: code}
+	}
+	old := []*Exchange{
+		post("operator", `{"agent_id":1}`, 201),
+		post("viewer", `{"agent_id":1}`, 403),
+	}
+
+	// requests that differ in user or body are not paired
+	cur := []*Exchange{
+		post("viewer", `{"agent_id":1}`, 403),
+		post("operator", `{"agent_id":2}`, 400),
+	}
+	if changes := compareExchanges("s:e:t", old, cur); len(changes) != 0 {
+		t.Errorf("got changes %+v, wanted none", changes)
+	}
+
+	// and a changed status code is a change, even with no body
+	cur = []*Exchange{post("operator", `{"agent_id":1}`, 500)}
+	changes := compareExchanges("s:e:t", old, cur)
+	if len(changes) != 1 || changes[0].OldCode != 201 || changes[0].NewCode != 500 {
+		t.Errorf("got changes %+v, wanted 201 -> 500", changes)
+	}
+}
+
+func TestDiffBodies(t *testing.T) {
+	tests := []struct {
+		name string
+		old  string
+		cur  string
+		same bool
+	}{
+		{"identical", `{"id": 4}`, `{"id": 4}`, true},
+		{"reordered", `{"id": 4, "status": "startup"}`, `{"status": "startup", "id": 4}`, true},
+		{"changed", `{"id": 4}`, `{"id": 5}`, false},
+		{
+			"different times",
+			`{"job": {"started_at": "2020-01-02T03:04:05Z"}}`,
+			`{"job": {"started_at": "2021-06-07T08:09:10.123456Z"}}`,
+			true,
+		},
+		{
+			"times in a list",
+			`{"jobs": [{"finished_at": "2020-01-02T03:04:05Z"}]}`,
+			`{"jobs": [{"finished_at": "2020-01-02T03:04:06Z"}]}`,
+			true,
+		},
+		{
+			"job started",
+			`{"job": {"started_at": "0001-01-01T00:00:00Z"}}`,
+			`{"job": {"started_at": "2020-01-02T03:04:05Z"}}`,
+			false,
+		},
+		{
+			"job still not started",
+			`{"job": {"started_at": "0001-01-01T00:00:00Z"}}`,
+			`{"job": {"started_at": "0001-01-01T00:00:00Z"}}`,
+			true,
+		},
+		{"not JSON", `not found`, `forbidden`, false},
+		{"JSON and not JSON", `{"id": 4}`, `not found`, false},
+	}
+
+	for _, tc := range tests {
+		diff, same := diffBodies(tc.old, tc.cur)
+		if same != tc.same {
+			t.Errorf("%s: got same %t, wanted %t (diff %q)", tc.name, same, tc.same, diff)
+		}
+		if same && diff != "" {
+			t.Errorf("%s: got diff %q for equivalent bodies", tc.name, diff)
+		}
+		if !same && diff == "" {
+			t.Errorf("%s: got no diff for different bodies", tc.name)
+		}
+	}
+}
+
+func TestMaskTimes(t *testing.T) {
+	v := map[string]interface{}{
+		"started_at":  "2020-01-02T03:04:05Z",
+		"finished_at": "0001-01-01T00:00:00Z",
+		"status":      "running",
+		"id":          4.0,
+		"history": []interface{}{
+			"2020-01-02T03:04:05.999999999+02:00",
+			"not a time",
+		},
+	}
+	want := map[string]interface{}{
+		"started_at":  maskedTime,
+		"finished_at": "0001-01-01T00:00:00Z",
+		"status":      "running",
+		"id":          4.0,
+		"history": []interface{}{
+			maskedTime,
+			"not a time",
+		},
+	}
+	if got := maskTimes(v); !reflect.DeepEqual(got, want) {
+		t.Errorf("got %v, wanted %v", got, want)
+	}
+}
@@ -0,0 +1,150 @@
+// SPDX-License-Identifier: Apache-2.0 OR GPL-2.0-or-later
+
+// Package fstree contains helpers for clearing, copying and
+// comparing directory trees, such as the /code and /spdx
+// volumes that are shared with the agents, and for naming the
+// files that the harness writes.
+package fstree
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+)
+
+// Clear deletes the contents of dir, but not dir itself.
+func Clear(dir string) error {
+	contents, err := ioutil.ReadDir(dir)
+	if err != nil {
+		return err
+	}
+	for _, c := range contents {
+		err = os.RemoveAll(filepath.Join(dir, c.Name()))
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// Copy recursively copies the contents of src into dst, which
+// must already exist.
+func Copy(src string, dst string) error {
+	return filepath.Walk(src, func(p string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		rel, err := filepath.Rel(src, p)
+		if err != nil {
+			return err
+		}
+		if rel == "." {
+			return nil
+		}
+		target := filepath.Join(dst, rel)
+
+		if info.IsDir() {
+			return os.MkdirAll(target, info.Mode().Perm()|0700)
+		}
+		return copyFile(p, target, info.Mode().Perm())
+	})
+}
+
+func copyFile(src string, dst string, perm os.FileMode) error {
+	in, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+
+	out, err := os.OpenFile(dst, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, perm)
+	if err != nil {
+		return err
+	}
+	_, err = io.Copy(out, in)
+	if err != nil {
+		out.Close()
+		return err
+	}
+	return out.Close()
+}
+
+// Files returns the slash-separated paths, relative to dir, of
+// all regular files under dir, in sorted order.
+func Files(dir string) ([]string, error) {
+	files := []string{}
+	err := filepath.Walk(dir, func(p string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if !info.Mode().IsRegular() {
+			return nil
+		}
+		rel, err := filepath.Rel(dir, p)
+		if err != nil {
+			return err
+		}
+		files = append(files, filepath.ToSlash(rel))
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	sort.Strings(files)
+	return files, nil
+}
+
+// Diff compares the regular files under got with those under
+// want. It returns one line per difference: files that are
+// missing from got, files that are only in got, and files whose
+// contents differ. An empty slice means the trees match.
+func Diff(got string, want string) ([]string, error) {
+	gotFiles, err := Files(got)
+	if err != nil {
+		return nil, err
+	}
+	wantFiles, err := Files(want)
+	if err != nil {
+		return nil, err
+	}
+
+	diffs := []string{}
+	gotSet := map[string]bool{}
+	for _, f := range gotFiles {
+		gotSet[f] = true
+	}
+
+	for _, f := range wantFiles {
+		if !gotSet[f] {
+			diffs = append(diffs, fmt.Sprintf("missing: %s", f))
+			continue
+		}
+		delete(gotSet, f)
+
+		gb, err := ioutil.ReadFile(filepath.Join(got, filepath.FromSlash(f)))
+		if err != nil {
+			return nil, err
+		}
+		wb, err := ioutil.ReadFile(filepath.Join(want, filepath.FromSlash(f)))
+		if err != nil {
+			return nil, err
+		}
+		if !bytes.Equal(gb, wb) {
+			diffs = append(diffs, fmt.Sprintf("differs: %s", f))
+		}
+	}
+
+	for _, f := range gotFiles {
+		if gotSet[f] {
+			diffs = append(diffs, fmt.Sprintf("unexpected: %s", f))
+		}
+	}
+
+	return diffs, nil
+}
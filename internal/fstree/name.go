@@ -0,0 +1,25 @@
+// SPDX-License-Identifier: Apache-2.0 OR GPL-2.0-or-later
+
+package fstree
+
+import (
+	"regexp"
+	"strings"
+)
+
+// unsafeNameChars matches runs of characters that should not
+// appear in a file name.
+var unsafeNameChars = regexp.MustCompile(`[^A-Za-z0-9._-]+`)
+
+// SafeName returns s converted to a form that is safe to use as
+// a single path element, e.g. for a file named after a test: each
+// run of unsafe characters becomes an underscore, and leading and
+// trailing underscores are removed. If nothing is left, it
+// returns "_".
+func SafeName(s string) string {
+	name := strings.Trim(unsafeNameChars.ReplaceAllString(s, "_"), "_")
+	if name == "" {
+		return "_"
+	}
+	return name
+}
@@ -0,0 +1,26 @@
+// SPDX-License-Identifier: Apache-2.0 OR GPL-2.0-or-later
+
+package fstree
+
+import "testing"
+
+func TestSafeName(t *testing.T) {
+	tests := []struct {
+		s    string
+		want string
+	}{
+		{"GET-success", "GET-success"},
+		{"endpoints:jobs/{id}:PUT (operator)", "endpoints_jobs_id_PUT_operator"},
+		{"/repopulls/{id}/jobs", "repopulls_id_jobs"},
+		{"v1.2_final", "v1.2_final"},
+		{"../..", ".._.."},
+		{"", "_"},
+		{"{}", "_"},
+	}
+
+	for _, tc := range tests {
+		if got := SafeName(tc.s); got != tc.want {
+			t.Errorf("%q: got %q, wanted %q", tc.s, got, tc.want)
+		}
+	}
+}
@@ -0,0 +1,139 @@
+// SPDX-License-Identifier: Apache-2.0 OR GPL-2.0-or-later
+
+// Package har records HTTP traffic and saves it in the HAR 1.2
+// format, so that it can be loaded into browser devtools or
+// other HAR tools.
+package har
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+
+	"github.com/swinslow/peridot-jobrunner-testing/internal/fstree"
+)
+
+// File is the top level of a HAR file.
+type File struct {
+	Log *Log `json:"log"`
+}
+
+// Log holds the recorded entries.
+type Log struct {
+	Version string   `json:"version"`
+	Creator *Creator `json:"creator"`
+	Entries []*Entry `json:"entries"`
+}
+
+// Creator identifies the program that created the HAR file.
+type Creator struct {
+	Name    string `json:"name"`
+	Version string `json:"version"`
+}
+
+// Entry is one HTTP request and its response.
+type Entry struct {
+	StartedDateTime string    `json:"startedDateTime"`
+	Time            float64   `json:"time"`
+	Request         *Request  `json:"request"`
+	Response        *Response `json:"response"`
+	Cache           struct{}  `json:"cache"`
+	Timings         *Timings  `json:"timings"`
+	Comment         string    `json:"comment,omitempty"`
+}
+
+// Request is the request half of an Entry.
+type Request struct {
+	Method      string       `json:"method"`
+	URL         string       `json:"url"`
+	HTTPVersion string       `json:"httpVersion"`
+	Cookies     []*NameValue `json:"cookies"`
+	Headers     []*NameValue `json:"headers"`
+	QueryString []*NameValue `json:"queryString"`
+	PostData    *PostData    `json:"postData,omitempty"`
+	HeadersSize int          `json:"headersSize"`
+	BodySize    int          `json:"bodySize"`
+}
+
+// Response is the response half of an Entry. If no response
+// was received, its Status is zero.
+type Response struct {
+	Status      int          `json:"status"`
+	StatusText  string       `json:"statusText"`
+	HTTPVersion string       `json:"httpVersion"`
+	Cookies     []*NameValue `json:"cookies"`
+	Headers     []*NameValue `json:"headers"`
+	Content     *Content     `json:"content"`
+	RedirectURL string       `json:"redirectURL"`
+	HeadersSize int          `json:"headersSize"`
+	BodySize    int          `json:"bodySize"`
+	Comment     string       `json:"comment,omitempty"`
+}
+
+// NameValue is a header, cookie or query string parameter.
+type NameValue struct {
+	Name  string `json:"name"`
+	Value string `json:"value"`
+}
+
+// PostData is a request body.
+type PostData struct {
+	MimeType string `json:"mimeType"`
+	Text     string `json:"text"`
+}
+
+// Content is a response body.
+type Content struct {
+	Size     int    `json:"size"`
+	MimeType string `json:"mimeType"`
+	Text     string `json:"text"`
+}
+
+// Timings breaks down an Entry's Time, in milliseconds. Only
+// the wait for the response headers and the time to receive
+// the body are measured.
+type Timings struct {
+	Send    float64 `json:"send"`
+	Wait    float64 `json:"wait"`
+	Receive float64 `json:"receive"`
+}
+
+// Save writes entries to the file at path as a HAR file.
+func Save(path string, entries []*Entry) error {
+	if entries == nil {
+		entries = []*Entry{}
+	}
+	f := &File{Log: &Log{
+		Version: "1.2",
+		Creator: &Creator{Name: "peridot-jobrunner-testing", Version: "1.0"},
+		Entries: entries,
+	}}
+	js, err := json.MarshalIndent(f, "", "  ")
+	if err != nil {
+		return err
+	}
+	return ioutil.WriteFile(path, js, 0644)
+}
+
+// Load reads the entries from the HAR file at path.
+func Load(path string) ([]*Entry, error) {
+	js, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	f := &File{}
+	err = json.Unmarshal(js, f)
+	if err != nil {
+		return nil, err
+	}
+	if f.Log == nil {
+		return nil, fmt.Errorf("no log in HAR file %s", path)
+	}
+	return f.Log.Entries, nil
+}
+
+// FileName returns a HAR file name for the test with the given
+// name, which may contain characters that are unsafe in paths.
+func FileName(name string) string {
+	return fstree.SafeName(name) + ".har"
+}
@@ -0,0 +1,168 @@
+// SPDX-License-Identifier: Apache-2.0 OR GPL-2.0-or-later
+
+package har
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+)
+
+// Player is an http.RoundTripper that answers each request with
+// a recorded response, instead of sending it. A request matches
+// an entry if its method, path, query and body are the same as
+// the entry's, and so is its Authorization header, unless that
+// was redacted when recording. Each entry is used only once, in
+// the order recorded, except that the last matching entry for a
+// GET is reused if the GET is repeated more times than it was
+// recorded, e.g. while polling a job. A request with no match
+// gets an error.
+type Player struct {
+	mu      sync.Mutex
+	entries []*Entry
+	used    []bool
+	misses  []string
+}
+
+// NewPlayer returns a Player that answers with entries.
+func NewPlayer(entries []*Entry) *Player {
+	return &Player{entries: entries, used: make([]bool, len(entries))}
+}
+
+// pathAndQuery returns the path and query of u, ignoring the
+// scheme and host, so that a recording can be replayed against
+// a different API root.
+func pathAndQuery(u string) string {
+	parsed, err := url.Parse(u)
+	if err != nil {
+		return u
+	}
+	return parsed.RequestURI()
+}
+
+// matches returns whether e was recorded for a request with the
+// given method, path and query, body and Authorization header.
+func matches(e *Entry, method string, pq string, body string, auth string) bool {
+	if e.Request.Method != method || pathAndQuery(e.Request.URL) != pq {
+		return false
+	}
+	recBody := ""
+	if e.Request.PostData != nil {
+		recBody = e.Request.PostData.Text
+	}
+	if recBody != body {
+		return false
+	}
+	for _, h := range e.Request.Headers {
+		if http.CanonicalHeaderKey(h.Name) == "Authorization" {
+			return h.Value == redacted || h.Value == auth
+		}
+	}
+	return auth == ""
+}
+
+// RoundTrip implements http.RoundTripper.
+func (p *Player) RoundTrip(req *http.Request) (*http.Response, error) {
+	body := ""
+	if req.Body != nil {
+		b, err := ioutil.ReadAll(req.Body)
+		req.Body.Close()
+		if err != nil {
+			return nil, err
+		}
+		body = string(b)
+	}
+	pq := req.URL.RequestURI()
+	auth := req.Header.Get("Authorization")
+
+	p.mu.Lock()
+	e := p.find(req.Method, pq, body, auth)
+	if e == nil {
+		miss := fmt.Sprintf("%s %s", req.Method, pq)
+		if body != "" {
+			miss += " " + body
+		}
+		p.misses = append(p.misses, miss)
+	}
+	p.mu.Unlock()
+
+	if e == nil {
+		return nil, fmt.Errorf("replay: no recorded response for %s %s", req.Method, pq)
+	}
+	if e.Response == nil || e.Response.Status == 0 {
+		msg := "no response was recorded"
+		if e.Response != nil && e.Response.Comment != "" {
+			msg = e.Response.Comment
+		}
+		return nil, fmt.Errorf("replay: %s", msg)
+	}
+
+	text := ""
+	if e.Response.Content != nil {
+		text = e.Response.Content.Text
+	}
+	resp := &http.Response{
+		Status:        fmt.Sprintf("%d %s", e.Response.Status, e.Response.StatusText),
+		StatusCode:    e.Response.Status,
+		Proto:         "HTTP/1.1",
+		ProtoMajor:    1,
+		ProtoMinor:    1,
+		Header:        http.Header{},
+		Body:          ioutil.NopCloser(bytes.NewReader([]byte(text))),
+		ContentLength: int64(len(text)),
+		Request:       req,
+	}
+	for _, h := range e.Response.Headers {
+		if strings.EqualFold(h.Name, "Content-Length") {
+			continue
+		}
+		resp.Header.Add(h.Name, h.Value)
+	}
+	return resp, nil
+}
+
+// find returns the first unused entry that matches, marking it
+// used, or for a GET the last used entry that matches. It must
+// be called with p.mu held.
+func (p *Player) find(method string, pq string, body string, auth string) *Entry {
+	lastUsed := -1
+	for i, e := range p.entries {
+		if !matches(e, method, pq, body, auth) {
+			continue
+		}
+		if !p.used[i] {
+			p.used[i] = true
+			return e
+		}
+		lastUsed = i
+	}
+	if method == "GET" && lastUsed >= 0 {
+		return p.entries[lastUsed]
+	}
+	return nil
+}
+
+// Misses returns a description of each request that had no
+// recorded response.
+func (p *Player) Misses() []string {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return append([]string{}, p.misses...)
+}
+
+// Unused returns how many recorded entries were never used.
+func (p *Player) Unused() int {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	n := 0
+	for _, u := range p.used {
+		if !u {
+			n++
+		}
+	}
+	return n
+}
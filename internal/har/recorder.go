@@ -0,0 +1,170 @@
+// SPDX-License-Identifier: Apache-2.0 OR GPL-2.0-or-later
+
+package har
+
+import (
+	"bytes"
+	"io/ioutil"
+	"net/http"
+	"sort"
+	"sync"
+	"time"
+)
+
+// redacted replaces the values of redacted headers.
+const redacted = "REDACTED"
+
+// Recorder is an http.RoundTripper that passes each request on
+// to Next, and records it with its response as an Entry.
+type Recorder struct {
+	// Next makes the actual requests.
+	Next http.RoundTripper
+
+	// Redact, if true, means that the values of Authorization
+	// headers are not recorded.
+	Redact bool
+
+	mu      sync.Mutex
+	entries []*Entry
+}
+
+// NewRecorder returns a Recorder that passes requests to next.
+func NewRecorder(next http.RoundTripper, redact bool) *Recorder {
+	return &Recorder{Next: next, Redact: redact}
+}
+
+// Take returns the entries recorded so far, and clears them.
+func (rec *Recorder) Take() []*Entry {
+	rec.mu.Lock()
+	defer rec.mu.Unlock()
+	entries := rec.entries
+	rec.entries = nil
+	return entries
+}
+
+// RoundTrip implements http.RoundTripper. It reads the full
+// request body, so that it can be recorded, and passes on a
+// clone of req with a copy of it, leaving req itself unchanged.
+// It reads the full response body too, and replaces it with a
+// copy for the caller.
+func (rec *Recorder) RoundTrip(req *http.Request) (*http.Response, error) {
+	var reqBody []byte
+	out := req
+	if req.Body != nil {
+		var err error
+		reqBody, err = ioutil.ReadAll(req.Body)
+		req.Body.Close()
+		if err != nil {
+			return nil, err
+		}
+		out = req.Clone(req.Context())
+		out.Body = ioutil.NopCloser(bytes.NewReader(reqBody))
+	}
+
+	start := time.Now()
+	e := &Entry{
+		StartedDateTime: start.Format(time.RFC3339Nano),
+		Request:         rec.request(req, reqBody),
+		Timings:         &Timings{},
+	}
+
+	resp, err := rec.Next.RoundTrip(out)
+	wait := time.Since(start)
+	if err != nil {
+		e.Response = &Response{
+			Cookies:     []*NameValue{},
+			Headers:     []*NameValue{},
+			Content:     &Content{},
+			HeadersSize: -1,
+			BodySize:    -1,
+			Comment:     err.Error(),
+		}
+		e.Timings.Wait = ms(wait)
+		e.Time = e.Timings.Wait
+		rec.add(e)
+		return nil, err
+	}
+
+	respBody, err := ioutil.ReadAll(resp.Body)
+	resp.Body.Close()
+	resp.Body = ioutil.NopCloser(bytes.NewReader(respBody))
+	total := time.Since(start)
+
+	e.Response = &Response{
+		Status:      resp.StatusCode,
+		StatusText:  http.StatusText(resp.StatusCode),
+		HTTPVersion: resp.Proto,
+		Cookies:     []*NameValue{},
+		Headers:     rec.headers(resp.Header),
+		Content: &Content{
+			Size:     len(respBody),
+			MimeType: resp.Header.Get("Content-Type"),
+			Text:     string(respBody),
+		},
+		HeadersSize: -1,
+		BodySize:    len(respBody),
+	}
+	e.Timings.Wait = ms(wait)
+	e.Timings.Receive = ms(total - wait)
+	e.Time = ms(total)
+	rec.add(e)
+
+	return resp, err
+}
+
+// add appends e to the recorded entries.
+func (rec *Recorder) add(e *Entry) {
+	rec.mu.Lock()
+	defer rec.mu.Unlock()
+	rec.entries = append(rec.entries, e)
+}
+
+// request converts req, whose body has already been read, to
+// its recorded form.
+func (rec *Recorder) request(req *http.Request, body []byte) *Request {
+	r := &Request{
+		Method:      req.Method,
+		URL:         req.URL.String(),
+		HTTPVersion: "HTTP/1.1",
+		Cookies:     []*NameValue{},
+		Headers:     rec.headers(req.Header),
+		QueryString: []*NameValue{},
+		HeadersSize: -1,
+		BodySize:    len(body),
+	}
+	for name, values := range req.URL.Query() {
+		for _, v := range values {
+			r.QueryString = append(r.QueryString, &NameValue{Name: name, Value: v})
+		}
+	}
+	sort.Slice(r.QueryString, func(i, j int) bool { return r.QueryString[i].Name < r.QueryString[j].Name })
+	if len(body) > 0 {
+		mimeType := req.Header.Get("Content-Type")
+		if mimeType == "" {
+			mimeType = "application/json"
+		}
+		r.PostData = &PostData{MimeType: mimeType, Text: string(body)}
+	}
+	return r
+}
+
+// headers converts h to its recorded form, sorted by name,
+// redacting Authorization headers if requested.
+func (rec *Recorder) headers(h http.Header) []*NameValue {
+	nvs := []*NameValue{}
+	for name, values := range h {
+		for _, v := range values {
+			if rec.Redact && http.CanonicalHeaderKey(name) == "Authorization" {
+				v = redacted
+			}
+			nvs = append(nvs, &NameValue{Name: name, Value: v})
+		}
+	}
+	sort.Slice(nvs, func(i, j int) bool { return nvs[i].Name < nvs[j].Name })
+	return nvs
+}
+
+// ms converts d to milliseconds.
+func ms(d time.Duration) float64 {
+	return float64(d) / float64(time.Millisecond)
+}
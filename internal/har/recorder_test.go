@@ -0,0 +1,48 @@
+// SPDX-License-Identifier: Apache-2.0 OR GPL-2.0-or-later
+
+package har
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestRecorderLeavesRequestUnchanged(t *testing.T) {
+	var sent string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		b, _ := ioutil.ReadAll(r.Body)
+		sent = string(b)
+		w.WriteHeader(201)
+	}))
+	defer srv.Close()
+
+	body := `{"agent_id":1}`
+	req, err := http.NewRequest("POST", srv.URL+"/repopulls/3/jobs", strings.NewReader(body))
+	if err != nil {
+		t.Fatal(err)
+	}
+	reqBody := req.Body
+	rec := NewRecorder(http.DefaultTransport, false)
+	resp, err := rec.RoundTrip(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+
+	if req.Body != reqBody {
+		t.Errorf("request body was replaced")
+	}
+	if sent != body {
+		t.Errorf("server got body %q, wanted %q", sent, body)
+	}
+	entries := rec.Take()
+	if len(entries) != 1 || entries[0].Request.PostData == nil || entries[0].Request.PostData.Text != body {
+		t.Fatalf("got entries %+v, wanted one with body %q", entries, body)
+	}
+	if entries[0].Response.Status != 201 {
+		t.Errorf("got recorded status %d, wanted 201", entries[0].Response.Status)
+	}
+}
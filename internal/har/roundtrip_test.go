@@ -0,0 +1,313 @@
+// SPDX-License-Identifier: Apache-2.0 OR GPL-2.0-or-later
+
+package har_test
+
+import (
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/swinslow/peridot-jobrunner-testing/fixtures"
+	"github.com/swinslow/peridot-jobrunner-testing/internal/har"
+	"github.com/swinslow/peridot-jobrunner-testing/internal/runner"
+	"github.com/swinslow/peridot-jobrunner-testing/internal/testresult"
+	"github.com/swinslow/peridot-jobrunner-testing/test/utils"
+)
+
+// fakeAPI is a stateful stand-in for the peridot API: a DB reset
+// clears its jobs, and each created job gets the next ID, so that
+// the same request gets different responses at different times.
+type fakeAPI struct {
+	mu     sync.Mutex
+	nextID int
+	jobs   map[string]bool
+}
+
+func (f *fakeAPI) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	switch {
+	case r.Method == "POST" && r.URL.Path == "/admin/db":
+		f.nextID = 5
+		f.jobs = map[string]bool{}
+		w.WriteHeader(204)
+	case r.Method == "POST" && r.URL.Path == "/repopulls/3/jobs":
+		id := fmt.Sprintf("%d", f.nextID)
+		f.nextID++
+		f.jobs[id] = true
+		w.WriteHeader(201)
+		fmt.Fprintf(w, `{"id": %s}`, id)
+	case r.Method == "GET" && strings.HasPrefix(r.URL.Path, "/jobs/"):
+		id := strings.TrimPrefix(r.URL.Path, "/jobs/")
+		if !f.jobs[id] {
+			w.WriteHeader(404)
+			return
+		}
+		fmt.Fprintf(w, `{"job": {"id": %s}}`, id)
+	case r.Method == "DELETE" && strings.HasPrefix(r.URL.Path, "/jobs/"):
+		delete(f.jobs, strings.TrimPrefix(r.URL.Path, "/jobs/"))
+		w.WriteHeader(204)
+	default:
+		w.WriteHeader(400)
+	}
+}
+
+// exchange is one request in a scripted run, and what it got.
+type exchange struct {
+	method string
+	path   string
+	body   string
+	code   int
+	got    string
+}
+
+// script is a run of two tests, each after a DB reset. Both
+// tests make some of the same requests, which get different
+// responses depending on what came before them.
+func script() []*exchange {
+	return []*exchange{
+		// fixture reset, then test 1
+		{method: "POST", path: "/admin/db", body: `{"command": "resetDB"}`},
+		{method: "POST", path: "/repopulls/3/jobs", body: `{"agent_id":1}`},
+		{method: "GET", path: "/jobs/5"},
+		{method: "DELETE", path: "/jobs/5"},
+		{method: "GET", path: "/jobs/5"},
+		// fixture reset, then test 2
+		{method: "POST", path: "/admin/db", body: `{"command": "resetDB"}`},
+		{method: "GET", path: "/jobs/5"},
+		{method: "POST", path: "/repopulls/3/jobs", body: `{"agent_id":1}`},
+		{method: "POST", path: "/repopulls/3/jobs", body: `{"agent_id":1}`},
+		{method: "GET", path: "/jobs/6"},
+	}
+}
+
+// run sends each request in exs through rt to root, and fills
+// in what it got.
+func run(t *testing.T, rt http.RoundTripper, root string, exs []*exchange) {
+	client := &http.Client{Transport: rt}
+	for _, ex := range exs {
+		req, err := http.NewRequest(ex.method, root+ex.path, strings.NewReader(ex.body))
+		if err != nil {
+			t.Fatal(err)
+		}
+		req.Header.Set("Authorization", "Bearer token")
+		resp, err := client.Do(req)
+		if err != nil {
+			t.Fatalf("%s %s: %v", ex.method, ex.path, err)
+		}
+		b, err := ioutil.ReadAll(resp.Body)
+		resp.Body.Close()
+		if err != nil {
+			t.Fatal(err)
+		}
+		ex.code = resp.StatusCode
+		ex.got = string(b)
+	}
+}
+
+func TestRecordThenReplay(t *testing.T) {
+	for _, redact := range []bool{false, true} {
+		srv := httptest.NewServer(&fakeAPI{})
+		rec := har.NewRecorder(http.DefaultTransport, redact)
+		recorded := script()
+		run(t, rec, srv.URL, recorded)
+		srv.Close()
+
+		dir, err := ioutil.TempDir("", "har-test")
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer os.RemoveAll(dir)
+		path := filepath.Join(dir, "run.har")
+		err = har.Save(path, rec.Take())
+		if err != nil {
+			t.Fatal(err)
+		}
+		entries, err := har.Load(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		// the server is gone, so every response must come
+		// from the recording
+		p := har.NewPlayer(entries)
+		replayed := script()
+		run(t, p, srv.URL, replayed)
+
+		for i := range recorded {
+			rx, px := recorded[i], replayed[i]
+			if rx.code != px.code || rx.got != px.got {
+				t.Errorf("redact %t, request %d (%s %s): recorded %d %q, replayed %d %q", redact, i, rx.method, rx.path, rx.code, rx.got, px.code, px.got)
+			}
+		}
+		if misses := p.Misses(); len(misses) != 0 {
+			t.Errorf("redact %t: got misses %v", redact, misses)
+		}
+		if unused := p.Unused(); unused != 0 {
+			t.Errorf("redact %t: got %d unused entries", redact, unused)
+		}
+	}
+}
+
+// dbFixture resets the fake API's DB via the API, as a
+// DBFixture does without snapshots.
+type dbFixture struct {
+	root string
+}
+
+func (f dbFixture) Reset() error {
+	return fixtures.ResetDB(f.root)
+}
+
+// createAndDelete creates a job, deletes it, and checks that the
+// code volume was left as it was seeded.
+func createAndDelete(root string) *testresult.TestResult {
+	res := &testresult.TestResult{Suite: "har", Element: "jobs", ID: "create and delete"}
+
+	err := utils.Post(res, "1", root+"/repopulls/3/jobs", `{"agent_id":1}`, 201, "operator")
+	if err != nil {
+		return res
+	}
+	id, err := utils.ParseID(res, "2")
+	if err != nil {
+		return res
+	}
+	url := fmt.Sprintf("%s/jobs/%d", root, id)
+	err = utils.Delete(res, "3", url, ``, 204, "operator")
+	if err != nil {
+		return res
+	}
+	err = utils.GetContent(res, "4", url, 404, "viewer")
+	if err != nil {
+		return res
+	}
+	err = utils.CheckTree(res, "5", utils.CodeDir, filepath.Join(utils.SeedDir, "code"))
+	if err != nil {
+		return res
+	}
+
+	utils.Pass(res)
+	return res
+}
+
+// createTwo creates two jobs, and checks that the second one
+// exists, which it only does if the DB was reset before it.
+func createTwo(root string) *testresult.TestResult {
+	res := &testresult.TestResult{Suite: "har", Element: "jobs", ID: "create two"}
+
+	for _, step := range []string{"1", "2"} {
+		err := utils.Post(res, step, root+"/repopulls/3/jobs", `{"agent_id":1}`, 201, "operator")
+		if err != nil {
+			return res
+		}
+	}
+	err := utils.GetContent(res, "3", root+"/jobs/6", 200, "viewer")
+	if err != nil {
+		return res
+	}
+
+	utils.Pass(res)
+	return res
+}
+
+func TestRecordThenReplayRunner(t *testing.T) {
+	dir, err := ioutil.TempDir("", "har-test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	seed := filepath.Join(dir, "seed")
+	err = os.MkdirAll(filepath.Join(seed, "code"), 0755)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = ioutil.WriteFile(filepath.Join(seed, "code", "hello.c"), []byte("int main() {}\n"), 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	code := filepath.Join(dir, "code")
+	err = os.MkdirAll(code, 0755)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	prevTransport, prevCode, prevSeed := utils.Transport, utils.CodeDir, utils.SeedDir
+	defer func() {
+		utils.Transport, utils.CodeDir, utils.SeedDir = prevTransport, prevCode, prevSeed
+		utils.Replay = false
+	}()
+	utils.SeedDir = seed
+	suites := []testresult.Suite{
+		{Name: "har", Tests: []testresult.Test{{Func: createAndDelete}, {Func: createTwo}}},
+	}
+
+	// record a run against the API, with the volumes in place
+	srv := httptest.NewServer(&fakeAPI{})
+	rec := har.NewRecorder(http.DefaultTransport, true)
+	utils.Transport = rec
+	utils.CodeDir = code
+	rn := &runner.Runner{
+		Root:    srv.URL,
+		DB:      dbFixture{srv.URL},
+		Volumes: fixtures.NewVolumeManager(&fixtures.Volume{Name: "code", Root: code, Seed: filepath.Join(seed, "code")}),
+		HAR:     rec,
+	}
+	recorded := rn.Run(suites)
+	srv.Close()
+	if len(rn.HookResults) != 0 {
+		t.Fatalf("recording: got hook failures %v", rn.HookResults[0].Err)
+	}
+	path := filepath.Join(dir, "run.har")
+	err = har.Save(path, rn.HAREntries)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// replay it with the server gone and no volumes, as the run
+	// subcommand does for -replay
+	entries, err := har.Load(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	p := har.NewPlayer(entries)
+	utils.Transport = p
+	utils.CodeDir = filepath.Join(dir, "missing")
+	utils.Replay = true
+	rn = &runner.Runner{
+		Root: srv.URL,
+		DB:   dbFixture{srv.URL},
+	}
+	replayed := rn.Run(suites)
+	if len(rn.HookResults) != 0 {
+		t.Fatalf("replay: got hook failures %v", rn.HookResults[0].Err)
+	}
+
+	if len(recorded) != len(replayed) {
+		t.Fatalf("recorded %d results, replayed %d", len(recorded), len(replayed))
+	}
+	for i := range recorded {
+		rr, pr := recorded[i], replayed[i]
+		if !rr.Success || !pr.Success {
+			t.Errorf("%s: recorded success %t (%v), replayed success %t (%v)", rr.Name(), rr.Success, rr.FailError, pr.Success, pr.FailError)
+		}
+		if string(rr.Got) != string(pr.Got) {
+			t.Errorf("%s: recorded %q, replayed %q", rr.Name(), rr.Got, pr.Got)
+		}
+	}
+	if want := "step 5 skipped in replay"; len(replayed[0].Diagnostics) != 1 || !strings.HasPrefix(replayed[0].Diagnostics[0], want) {
+		t.Errorf("got diagnostics %v, wanted one starting %q", replayed[0].Diagnostics, want)
+	}
+	if misses := p.Misses(); len(misses) != 0 {
+		t.Errorf("got misses %v", misses)
+	}
+	if unused := p.Unused(); unused != 0 {
+		t.Errorf("got %d unused entries", unused)
+	}
+}
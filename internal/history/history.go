@@ -0,0 +1,167 @@
+// SPDX-License-Identifier: Apache-2.0 OR GPL-2.0-or-later
+
+// Package history keeps a local record of test outcomes across
+// runs, so that tests with unstable pass rates can be flagged.
+package history
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"sort"
+	"time"
+
+	"github.com/swinslow/peridot-jobrunner-testing/internal/testresult"
+)
+
+// maxRecent is how many recent outcomes are kept per test.
+const maxRecent = 20
+
+// Outcome is how one run of a test went.
+type Outcome string
+
+const (
+	// Pass means that the test passed on the first try.
+	Pass Outcome = "pass"
+
+	// Flaky means that the test failed at first, but passed on
+	// a rerun.
+	Flaky Outcome = "flaky"
+
+	// Fail means that the test failed on every try.
+	Fail Outcome = "fail"
+)
+
+// outcome returns how the run of r went.
+func outcome(r *testresult.TestResult) Outcome {
+	switch {
+	case r.Success:
+		return Pass
+	case r.Flaky:
+		return Flaky
+	default:
+		return Fail
+	}
+}
+
+// Record holds the outcomes of one test across runs.
+type Record struct {
+	// Runs is the total number of runs recorded.
+	Runs int `json:"runs"`
+
+	// Passes is how many of those runs passed on the first try.
+	Passes int `json:"passes"`
+
+	// Flaky is how many of those runs failed at first but
+	// passed on a rerun.
+	Flaky int `json:"flaky"`
+
+	// Recent holds the most recent outcomes, oldest first.
+	Recent []Outcome `json:"recent"`
+
+	// LastRun is when the test was last recorded.
+	LastRun time.Time `json:"last_run"`
+}
+
+// PassRate returns the fraction of recorded runs that passed
+// on the first try.
+func (rec *Record) PassRate() float64 {
+	if rec.Runs == 0 {
+		return 0
+	}
+	return float64(rec.Passes) / float64(rec.Runs)
+}
+
+// Unstable returns whether the test's recent outcomes include
+// both passes and failures, or any flaky run, which both failed
+// and passed.
+func (rec *Record) Unstable() bool {
+	sawPass, sawFail := false, false
+	for _, o := range rec.Recent {
+		switch o {
+		case Pass:
+			sawPass = true
+		case Flaky:
+			return true
+		default:
+			sawFail = true
+		}
+	}
+	return sawPass && sawFail
+}
+
+// History holds a Record for each test, keyed by the test's
+// Suite:Element:ID name.
+type History struct {
+	Tests map[string]*Record `json:"tests"`
+}
+
+// Load reads the history file at path. If the file does not
+// exist, it returns an empty History.
+func Load(path string) (*History, error) {
+	h := &History{Tests: map[string]*Record{}}
+
+	b, err := ioutil.ReadFile(path)
+	if os.IsNotExist(err) {
+		return h, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	err = json.Unmarshal(b, h)
+	if err != nil {
+		return nil, err
+	}
+	if h.Tests == nil {
+		h.Tests = map[string]*Record{}
+	}
+	return h, nil
+}
+
+// Save writes the history to the file at path.
+func (h *History) Save(path string) error {
+	b, err := json.MarshalIndent(h, "", "  ")
+	if err != nil {
+		return err
+	}
+	return ioutil.WriteFile(path, b, 0644)
+}
+
+// Add records the outcome of each result from one run.
+func (h *History) Add(results []*testresult.TestResult, at time.Time) {
+	for _, r := range results {
+		rec, ok := h.Tests[r.Name()]
+		if !ok {
+			rec = &Record{}
+			h.Tests[r.Name()] = rec
+		}
+
+		o := outcome(r)
+		rec.Runs++
+		switch o {
+		case Pass:
+			rec.Passes++
+		case Flaky:
+			rec.Flaky++
+		}
+		rec.Recent = append(rec.Recent, o)
+		if len(rec.Recent) > maxRecent {
+			rec.Recent = rec.Recent[len(rec.Recent)-maxRecent:]
+		}
+		rec.LastRun = at
+	}
+}
+
+// Unstable returns the names of all tests whose recent history
+// is unstable, in sorted order.
+func (h *History) Unstable() []string {
+	names := []string{}
+	for name, rec := range h.Tests {
+		if rec.Unstable() {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+	return names
+}
@@ -0,0 +1,124 @@
+// SPDX-License-Identifier: Apache-2.0 OR GPL-2.0-or-later
+
+package history
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/swinslow/peridot-jobrunner-testing/internal/testresult"
+)
+
+// result returns the result of one run of the test named id.
+func result(id string, o Outcome) *testresult.TestResult {
+	return &testresult.TestResult{
+		Suite:   "s",
+		Element: "e",
+		ID:      id,
+		Success: o == Pass,
+		Flaky:   o == Flaky,
+	}
+}
+
+func TestAddCounts(t *testing.T) {
+	h := &History{Tests: map[string]*Record{}}
+	at := time.Date(2020, 1, 2, 3, 4, 5, 0, time.UTC)
+	for _, o := range []Outcome{Pass, Flaky, Fail, Pass} {
+		h.Add([]*testresult.TestResult{result("t", o)}, at)
+	}
+
+	rec := h.Tests["s:e:t"]
+	if rec == nil {
+		t.Fatalf("no record for s:e:t in %v", h.Tests)
+	}
+	if rec.Runs != 4 || rec.Passes != 2 || rec.Flaky != 1 {
+		t.Errorf("got %d runs, %d passes, %d flaky, wanted 4, 2, 1", rec.Runs, rec.Passes, rec.Flaky)
+	}
+	if want := []Outcome{Pass, Flaky, Fail, Pass}; !reflect.DeepEqual(rec.Recent, want) {
+		t.Errorf("got recent %v, wanted %v", rec.Recent, want)
+	}
+	if rec.PassRate() != 0.5 {
+		t.Errorf("got pass rate %v, wanted 0.5", rec.PassRate())
+	}
+	if !rec.LastRun.Equal(at) {
+		t.Errorf("got last run %v, wanted %v", rec.LastRun, at)
+	}
+}
+
+func TestAddKeepsMaxRecent(t *testing.T) {
+	h := &History{Tests: map[string]*Record{}}
+	for i := 0; i < maxRecent; i++ {
+		h.Add([]*testresult.TestResult{result("t", Fail)}, time.Now())
+	}
+	h.Add([]*testresult.TestResult{result("t", Pass)}, time.Now())
+
+	rec := h.Tests["s:e:t"]
+	if rec.Runs != maxRecent+1 || len(rec.Recent) != maxRecent {
+		t.Fatalf("got %d runs, %d recent, wanted %d, %d", rec.Runs, len(rec.Recent), maxRecent+1, maxRecent)
+	}
+	if rec.Recent[maxRecent-1] != Pass {
+		t.Errorf("got last recent outcome %v, wanted %v", rec.Recent[maxRecent-1], Pass)
+	}
+}
+
+func TestUnstable(t *testing.T) {
+	tests := []struct {
+		name     string
+		outcomes []Outcome
+		want     bool
+	}{
+		{"no runs", nil, false},
+		{"always passes", []Outcome{Pass, Pass, Pass}, false},
+		{"always fails", []Outcome{Fail, Fail, Fail}, false},
+		{"passes and fails", []Outcome{Pass, Fail, Pass}, true},
+		{"always flaky", []Outcome{Flaky, Flaky, Flaky}, true},
+		{"once flaky", []Outcome{Pass, Pass, Flaky}, true},
+	}
+
+	for _, tc := range tests {
+		h := &History{Tests: map[string]*Record{}}
+		for _, o := range tc.outcomes {
+			h.Add([]*testresult.TestResult{result("t", o), result("stable", Pass)}, time.Now())
+		}
+		var want []string
+		if tc.want {
+			want = []string{"s:e:t"}
+		} else {
+			want = []string{}
+		}
+		if got := h.Unstable(); !reflect.DeepEqual(got, want) {
+			t.Errorf("%s: got unstable %v, wanted %v", tc.name, got, want)
+		}
+	}
+}
+
+func TestSaveThenLoad(t *testing.T) {
+	dir, err := ioutil.TempDir("", "history-test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "history.json")
+
+	h, err := Load(path)
+	if err != nil || len(h.Tests) != 0 {
+		t.Fatalf("missing file: got %v, %v, wanted an empty history", h.Tests, err)
+	}
+	h.Add([]*testresult.TestResult{result("t", Flaky)}, time.Date(2020, 1, 2, 3, 4, 5, 0, time.UTC))
+	err = h.Save(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	loaded, err := Load(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(loaded, h) {
+		t.Errorf("got %+v, wanted %+v", loaded.Tests["s:e:t"], h.Tests["s:e:t"])
+	}
+}
@@ -0,0 +1,263 @@
+// SPDX-License-Identifier: Apache-2.0 OR GPL-2.0-or-later
+
+// Package load replays request scenarios against the API at a
+// configurable rate and concurrency, and reports throughput,
+// error rates and latency percentiles for each scenario.
+package load
+
+import (
+	"fmt"
+	"io"
+	"io/ioutil"
+	"math"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+	"text/tabwriter"
+	"time"
+
+	"github.com/swinslow/peridot-jobrunner-testing/test/utils"
+)
+
+// Config controls a load run.
+type Config struct {
+	// Root is the API root URL.
+	Root string
+
+	// Scenarios are replayed round-robin.
+	Scenarios []*Scenario
+
+	// Rate is the total number of requests per second to
+	// start, across all workers. If zero, each worker sends
+	// its next request as soon as the last one finishes.
+	Rate float64
+
+	// Concurrency is the number of requests that may be in
+	// flight at once.
+	Concurrency int
+
+	// Duration is how long to keep starting new requests.
+	Duration time.Duration
+
+	// Timeout is the per-request timeout.
+	Timeout time.Duration
+}
+
+// DefaultMaxErrorRate is the highest fraction of a scenario's
+// requests that may fail before a load run is reported as
+// failing, unless another limit is given.
+const DefaultMaxErrorRate = 0.01
+
+// Stats holds the results for one scenario.
+type Stats struct {
+	Scenario *Scenario
+	Requests int
+	Errors   int
+
+	// Latencies holds the latency of each successful request.
+	// When requests are started at a fixed rate, each latency
+	// is measured from when the request was scheduled to start,
+	// not from when a worker was free to send it, so that time
+	// spent queued behind slow requests is not hidden.
+	Latencies []time.Duration
+}
+
+// ErrorRate returns the fraction of requests that failed.
+func (s *Stats) ErrorRate() float64 {
+	if s.Requests == 0 {
+		return 0
+	}
+	return float64(s.Errors) / float64(s.Requests)
+}
+
+// Percentile returns the p'th percentile latency, for p between
+// 0 and 100, using the nearest-rank method. Latencies must
+// already be sorted.
+func (s *Stats) Percentile(p float64) time.Duration {
+	n := len(s.Latencies)
+	if n == 0 {
+		return 0
+	}
+	rank := int(p/100*float64(n)+0.5) - 1
+	if rank < 0 {
+		rank = 0
+	}
+	if rank >= n {
+		rank = n - 1
+	}
+	return s.Latencies[rank]
+}
+
+// Report holds the results of a load run.
+type Report struct {
+	Elapsed time.Duration
+	Stats   []*Stats
+
+	// Rate is the configured request rate, or zero if requests
+	// were sent as fast as possible.
+	Rate float64
+
+	// Missed is the number of requests that were scheduled to
+	// start before the duration ended, but that were not started
+	// because every worker was busy.
+	Missed int
+}
+
+// Achieved returns the rate at which requests were actually
+// started, in requests per second.
+func (rep *Report) Achieved() float64 {
+	n := 0
+	for _, st := range rep.Stats {
+		n += st.Requests
+	}
+	return float64(n) / rep.Elapsed.Seconds()
+}
+
+// Run performs a load run as described by cfg.
+func Run(cfg Config) (*Report, error) {
+	if len(cfg.Scenarios) == 0 {
+		return nil, fmt.Errorf("no scenarios selected")
+	}
+	if cfg.Concurrency < 1 {
+		return nil, fmt.Errorf("concurrency must be at least 1, got %d", cfg.Concurrency)
+	}
+
+	stats := map[*Scenario]*Stats{}
+	for _, s := range cfg.Scenarios {
+		stats[s] = &Stats{Scenario: s}
+	}
+	var mu sync.Mutex
+
+	client := &http.Client{
+		Timeout: cfg.Timeout,
+		Transport: &http.Transport{
+			MaxIdleConnsPerHost: cfg.Concurrency,
+		},
+	}
+
+	// tokens hands out each request to start; it is closed once
+	// the duration has passed. With a fixed rate, each request
+	// has a scheduled start time. If every worker is busy, the
+	// schedule is not moved back: the requests that fell behind
+	// are started as soon as workers are free, and their latency
+	// still counts from when they were scheduled.
+	type token struct {
+		scenario  *Scenario
+		scheduled time.Time
+	}
+	tokens := make(chan token)
+	start := time.Now()
+	deadline := start.Add(cfg.Duration)
+	sent := 0
+	go func() {
+		defer close(tokens)
+		for i := 0; ; i++ {
+			t := token{scenario: cfg.Scenarios[i%len(cfg.Scenarios)]}
+			if cfg.Rate > 0 {
+				t.scheduled = start.Add(time.Duration(float64(i) * float64(time.Second) / cfg.Rate))
+				if !t.scheduled.Before(deadline) {
+					return
+				}
+				time.Sleep(time.Until(t.scheduled))
+			}
+			if !time.Now().Before(deadline) {
+				return
+			}
+			tokens <- t
+			sent++
+		}
+	}()
+
+	var wg sync.WaitGroup
+	for w := 0; w < cfg.Concurrency; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for t := range tokens {
+				latency, ok := send(client, cfg.Root, t.scenario, t.scheduled)
+				mu.Lock()
+				st := stats[t.scenario]
+				st.Requests++
+				if ok {
+					st.Latencies = append(st.Latencies, latency)
+				} else {
+					st.Errors++
+				}
+				mu.Unlock()
+			}
+		}()
+	}
+	wg.Wait()
+
+	rep := &Report{Elapsed: time.Since(start), Rate: cfg.Rate}
+	if cfg.Rate > 0 {
+		scheduled := int(math.Ceil(cfg.Duration.Seconds() * cfg.Rate))
+		if scheduled > sent {
+			rep.Missed = scheduled - sent
+		}
+	}
+	for _, s := range cfg.Scenarios {
+		st := stats[s]
+		sort.Slice(st.Latencies, func(i, k int) bool { return st.Latencies[i] < st.Latencies[k] })
+		rep.Stats = append(rep.Stats, st)
+	}
+	return rep, nil
+}
+
+// send makes one request for s, and returns its latency and
+// whether it got the expected status code. The latency is
+// measured from scheduled, unless it is zero.
+func send(client *http.Client, root string, s *Scenario, scheduled time.Time) (time.Duration, bool) {
+	var body io.Reader
+	if s.Body != "" {
+		body = strings.NewReader(s.Body)
+	}
+	req, err := http.NewRequest(s.Method, root+s.Path, body)
+	if err != nil {
+		return 0, false
+	}
+	utils.AddAuthHeader(nil, "", req, s.User)
+
+	start := scheduled
+	if start.IsZero() {
+		start = time.Now()
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return 0, false
+	}
+	_, err = io.Copy(ioutil.Discard, resp.Body)
+	resp.Body.Close()
+	latency := time.Since(start)
+
+	return latency, err == nil && resp.StatusCode == s.Code
+}
+
+// Print writes the report as a table to w.
+func (rep *Report) Print(w io.Writer) {
+	tw := tabwriter.NewWriter(w, 8, 4, 1, ' ', 0)
+	fmt.Fprintf(tw, "scenario\tendpoint\trequests\treq/s\terrors\tp50\tp95\tp99\n")
+	for _, st := range rep.Stats {
+		s := st.Scenario
+		throughput := float64(st.Requests) / rep.Elapsed.Seconds()
+		fmt.Fprintf(tw, "%s\t%s %s\t%d\t%.1f\t%.2f%%\t%s\t%s\t%s\n",
+			s.Name, s.Method, s.Path, st.Requests, throughput, 100*st.ErrorRate(),
+			round(st.Percentile(50)), round(st.Percentile(95)), round(st.Percentile(99)))
+	}
+	tw.Flush()
+
+	if rep.Rate > 0 {
+		fmt.Fprintf(w, "\ntarget %.1f req/s, achieved %.1f req/s", rep.Rate, rep.Achieved())
+		if rep.Missed > 0 {
+			fmt.Fprintf(w, "; %d scheduled requests were never started because every worker was busy", rep.Missed)
+		}
+		fmt.Fprintf(w, "\n")
+	}
+}
+
+// round rounds latencies to a precision that is readable in
+// the report.
+func round(d time.Duration) time.Duration {
+	return d.Round(10 * time.Microsecond)
+}
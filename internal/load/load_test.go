@@ -0,0 +1,156 @@
+// SPDX-License-Identifier: Apache-2.0 OR GPL-2.0-or-later
+
+package load
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestPercentile(t *testing.T) {
+	// 1ms to 100ms
+	hundred := &Stats{}
+	for i := 1; i <= 100; i++ {
+		hundred.Latencies = append(hundred.Latencies, time.Duration(i)*time.Millisecond)
+	}
+	three := &Stats{Latencies: []time.Duration{10 * time.Millisecond, 20 * time.Millisecond, 30 * time.Millisecond}}
+	one := &Stats{Latencies: []time.Duration{7 * time.Millisecond}}
+
+	tests := []struct {
+		name string
+		s    *Stats
+		p    float64
+		want time.Duration
+	}{
+		{"p0 of 100", hundred, 0, 1 * time.Millisecond},
+		{"p50 of 100", hundred, 50, 50 * time.Millisecond},
+		{"p95 of 100", hundred, 95, 95 * time.Millisecond},
+		{"p99 of 100", hundred, 99, 99 * time.Millisecond},
+		{"p100 of 100", hundred, 100, 100 * time.Millisecond},
+		{"p50 of 3", three, 50, 20 * time.Millisecond},
+		{"p99 of 3", three, 99, 30 * time.Millisecond},
+		{"p1 of 3", three, 1, 10 * time.Millisecond},
+		{"p99 of 1", one, 99, 7 * time.Millisecond},
+		{"p50 of none", &Stats{}, 50, 0},
+	}
+
+	for _, tc := range tests {
+		if got := tc.s.Percentile(tc.p); got != tc.want {
+			t.Errorf("%s: got %s, wanted %s", tc.name, got, tc.want)
+		}
+	}
+}
+
+func TestErrorRate(t *testing.T) {
+	tests := []struct {
+		requests int
+		errors   int
+		want     float64
+		over     bool
+	}{
+		{0, 0, 0, false},
+		{100, 0, 0, false},
+		{100, 1, 0.01, false},
+		{1000, 11, 0.011, true},
+		{100, 2, 0.02, true},
+		{4, 4, 1, true},
+	}
+
+	for _, tc := range tests {
+		s := &Stats{Requests: tc.requests, Errors: tc.errors}
+		got := s.ErrorRate()
+		if got != tc.want {
+			t.Errorf("%d of %d: got error rate %v, wanted %v", tc.errors, tc.requests, got, tc.want)
+		}
+		if (got > DefaultMaxErrorRate) != tc.over {
+			t.Errorf("%d of %d: got over default limit %t, wanted %t", tc.errors, tc.requests, !tc.over, tc.over)
+		}
+	}
+}
+
+// server returns a test server that takes delay to answer each
+// request with 200.
+func server(delay time.Duration) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		time.Sleep(delay)
+	}))
+}
+
+var scenario = &Scenario{Name: "get", Method: "GET", Path: "/jobs/4", User: "viewer", Code: 200}
+
+func TestRunKeepsUp(t *testing.T) {
+	srv := server(0)
+	defer srv.Close()
+
+	rep, err := Run(Config{
+		Root:        srv.URL,
+		Scenarios:   []*Scenario{scenario},
+		Rate:        50,
+		Concurrency: 4,
+		Duration:    200 * time.Millisecond,
+		Timeout:     time.Second,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	st := rep.Stats[0]
+	if rep.Missed != 0 || st.Requests != 10 || st.Errors != 0 {
+		t.Errorf("got %d requests, %d errors, %d missed, wanted 10, 0, 0", st.Requests, st.Errors, rep.Missed)
+	}
+	if len(st.Latencies) != st.Requests {
+		t.Errorf("got %d latencies for %d requests", len(st.Latencies), st.Requests)
+	}
+	var out bytes.Buffer
+	rep.Print(&out)
+	if !strings.Contains(out.String(), "target 50.0 req/s") || strings.Contains(out.String(), "never started") {
+		t.Errorf("got report %q, wanted the target rate and no missed requests", out.String())
+	}
+}
+
+func TestRunFallsBehind(t *testing.T) {
+	delay := 50 * time.Millisecond
+	srv := server(delay)
+	defer srv.Close()
+
+	// one worker can only start a request every 50ms, but one
+	// is scheduled every 10ms
+	rep, err := Run(Config{
+		Root:        srv.URL,
+		Scenarios:   []*Scenario{scenario},
+		Rate:        100,
+		Concurrency: 1,
+		Duration:    200 * time.Millisecond,
+		Timeout:     time.Second,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	st := rep.Stats[0]
+	if st.Requests+rep.Missed != 20 {
+		t.Errorf("got %d requests and %d missed, wanted 20 scheduled in all", st.Requests, rep.Missed)
+	}
+	if rep.Missed < 10 {
+		t.Errorf("got %d missed, wanted at least 10", rep.Missed)
+	}
+	if rep.Achieved() >= rep.Rate {
+		t.Errorf("got achieved rate %.1f, wanted less than %.1f", rep.Achieved(), rep.Rate)
+	}
+
+	// requests that waited for the worker count that wait in
+	// their latency, so the slowest took well over the delay
+	if max := st.Percentile(100); max < 2*delay {
+		t.Errorf("got max latency %s, wanted at least %s from its scheduled start", max, 2*delay)
+	}
+
+	var out bytes.Buffer
+	rep.Print(&out)
+	if !strings.Contains(out.String(), "scheduled requests were never started") {
+		t.Errorf("got report %q, wanted missed requests reported", out.String())
+	}
+}
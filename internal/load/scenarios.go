@@ -0,0 +1,58 @@
+// SPDX-License-Identifier: Apache-2.0 OR GPL-2.0-or-later
+
+package load
+
+// Scenario is one kind of request that is replayed under load.
+type Scenario struct {
+	// Name identifies the scenario on the command line and in
+	// the report, e.g. "list-jobs".
+	Name string
+
+	// Method is the HTTP method.
+	Method string
+
+	// Path is the URL path, relative to the API root.
+	Path string
+
+	// User is the github username to authenticate as.
+	User string
+
+	// Body is the request body, if any.
+	Body string
+
+	// Code is the HTTP status code expected on success; any
+	// other code counts as an error.
+	Code int
+}
+
+// Scenarios are all of the scenarios that can be selected.
+// They assume the API is in the fixture state when the load
+// run starts; write scenarios will add to that state as the
+// run goes on.
+var Scenarios = []*Scenario{
+	{"list-jobs", "GET", "/repopulls/4/jobs", "viewer", "", 200},
+	{"get-job", "GET", "/jobs/4", "viewer", "", 200},
+	{"list-agents", "GET", "/agents", "viewer", "", 200},
+	{"create-job", "POST", "/repopulls/3/jobs", "operator", `{"agent_id":1, "is_ready":false, "priorjob_ids":[], "config":{"kv": {"hello": "world"}}}`, 201},
+	{"update-job", "PUT", "/jobs/4", "operator", `{"is_ready": false}`, 204},
+}
+
+// ScenarioNames returns the names of all scenarios.
+func ScenarioNames() []string {
+	names := []string{}
+	for _, s := range Scenarios {
+		names = append(names, s.Name)
+	}
+	return names
+}
+
+// FindScenario returns the scenario with the given name, or nil
+// if there is none.
+func FindScenario(name string) *Scenario {
+	for _, s := range Scenarios {
+		if s.Name == name {
+			return s
+		}
+	}
+	return nil
+}
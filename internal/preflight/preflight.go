@@ -0,0 +1,328 @@
+// SPDX-License-Identifier: Apache-2.0 OR GPL-2.0-or-later
+
+// Package preflight collects the version and health of each
+// service under test before the tests run, so that reports can
+// show which component versions were tested.
+package preflight
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+	"time"
+)
+
+// probeTimeout limits how long each query may take.
+const probeTimeout = 5 * time.Second
+
+// Service is the version and health of one service.
+type Service struct {
+	// Name identifies the service, e.g. "api" or "agent nop".
+	Name string `json:"name"`
+
+	// Address is where the service was queried.
+	Address string `json:"address"`
+
+	// Healthy is whether the service responded.
+	Healthy bool `json:"healthy"`
+
+	// Version and Build are the version and build info that
+	// the service reported, if any.
+	Version string `json:"version,omitempty"`
+	Build   string `json:"build,omitempty"`
+
+	// Detail describes why the service is unhealthy, or why
+	// its version is not known.
+	Detail string `json:"detail,omitempty"`
+}
+
+// Environment describes the services under test.
+type Environment struct {
+	Collected time.Time  `json:"collected"`
+	Services  []*Service `json:"services"`
+}
+
+// Agent is an agent as registered with the API.
+type Agent struct {
+	Name    string `json:"name"`
+	Address string `json:"address"`
+	Port    int    `json:"port"`
+}
+
+// Config describes where to find the services.
+type Config struct {
+	// APIRoot is the root URL of the peridot API.
+	APIRoot string
+
+	// JobrunnerAddr is the host:port of the jobrunner's gRPC
+	// server. If empty, the jobrunner is not queried.
+	JobrunnerAddr string
+
+	// Client makes the HTTP calls to the API.
+	Client *http.Client
+
+	// Authorize adds an auth header to the request that lists
+	// the agents.
+	Authorize func(req *http.Request)
+
+	// FallbackAgents holds the host:port gRPC address of each
+	// agent to query, keyed by name, if the API has no agents
+	// registered, e.g. before the fixtures are set up.
+	FallbackAgents map[string]string
+
+	// Versions holds the versions of the services that cannot
+	// report their own, i.e. the jobrunner and the agents, keyed
+	// by service name, e.g. "jobrunner" or "agent nop". See
+	// EnvVersions.
+	Versions map[string]string
+}
+
+// VersionEnvPrefix starts the names of the environment variables
+// that EnvVersions reads, e.g. PERIDOT_VERSION_AGENT_NOP.
+const VersionEnvPrefix = "PERIDOT_VERSION_"
+
+// EnvVersions returns the service versions given by environment
+// variables in environ, which is in the form returned by
+// os.Environ. The rest of each variable's name, after
+// VersionEnvPrefix, is the service name in upper case, with
+// underscores for spaces; e.g. PERIDOT_VERSION_AGENT_NOP=v0.1.2
+// gives version v0.1.2 for "agent nop". Empty values are ignored.
+func EnvVersions(environ []string) map[string]string {
+	versions := map[string]string{}
+	for _, kv := range environ {
+		i := strings.Index(kv, "=")
+		if i < 0 || !strings.HasPrefix(kv[:i], VersionEnvPrefix) || kv[i+1:] == "" {
+			continue
+		}
+		name := strings.ToLower(strings.Replace(kv[len(VersionEnvPrefix):i], "_", " ", -1))
+		versions[name] = kv[i+1:]
+	}
+	return versions
+}
+
+// versionEnvVar returns the environment variable that gives the
+// version of the named service.
+func versionEnvVar(name string) string {
+	return VersionEnvPrefix + strings.ToUpper(strings.Replace(name, " ", "_", -1))
+}
+
+// Collect queries each service for its version and health.
+// A service that cannot be queried is recorded as unhealthy,
+// rather than stopping the collection.
+func Collect(cfg Config) *Environment {
+	env := &Environment{Collected: time.Now()}
+	env.Services = append(env.Services, apiService(cfg))
+
+	if cfg.JobrunnerAddr != "" {
+		env.Services = append(env.Services, grpcService("jobrunner", cfg.JobrunnerAddr, cfg.Versions))
+	}
+
+	agents, err := listAgents(cfg)
+	if err != nil || len(agents) == 0 {
+		agents = cfg.FallbackAgents
+	}
+	names := []string{}
+	for name := range agents {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		env.Services = append(env.Services, grpcService("agent "+name, agents[name], cfg.Versions))
+	}
+
+	return env
+}
+
+// apiService queries the API's health endpoint, which reports
+// its version and build info alongside its status.
+func apiService(cfg Config) *Service {
+	svc := &Service{Name: "api", Address: cfg.APIRoot}
+
+	req, err := http.NewRequest("GET", cfg.APIRoot+"/health", nil)
+	if err != nil {
+		svc.Detail = err.Error()
+		return svc
+	}
+	resp, err := cfg.Client.Do(req)
+	if err != nil {
+		svc.Detail = err.Error()
+		return svc
+	}
+	defer resp.Body.Close()
+	b, _ := ioutil.ReadAll(resp.Body)
+
+	var js struct {
+		Status  string `json:"status"`
+		Version string `json:"version"`
+		Build   string `json:"build"`
+		Commit  string `json:"commit"`
+	}
+	parseErr := json.Unmarshal(b, &js)
+
+	svc.Healthy = resp.StatusCode == 200
+	svc.Version = js.Version
+	svc.Build = js.Build
+	if svc.Build == "" {
+		svc.Build = js.Commit
+	}
+	switch {
+	case !svc.Healthy:
+		svc.Detail = fmt.Sprintf("health check returned HTTP status code %d: %s", resp.StatusCode, b)
+	case parseErr != nil:
+		svc.Detail = "health check response is not JSON, so version is unknown"
+	case svc.Version == "":
+		svc.Detail = "health check response has no version"
+	}
+	return svc
+}
+
+// grpcService checks that a gRPC service is listening at addr.
+// The peridot gRPC services do not report their version, so it
+// is taken from versions, if it is there.
+func grpcService(name string, addr string, versions map[string]string) *Service {
+	svc := &Service{Name: name, Address: addr, Version: versions[name]}
+	conn, err := net.DialTimeout("tcp", addr, probeTimeout)
+	if err != nil {
+		svc.Detail = err.Error()
+		return svc
+	}
+	conn.Close()
+	svc.Healthy = true
+	if svc.Version == "" {
+		svc.Detail = fmt.Sprintf("listening; version not available over gRPC, set %s to record it", versionEnvVar(name))
+	} else {
+		svc.Detail = fmt.Sprintf("listening; version from %s", versionEnvVar(name))
+	}
+	return svc
+}
+
+// listAgents returns the host:port gRPC address of each agent
+// registered with the API, keyed by name.
+func listAgents(cfg Config) (map[string]string, error) {
+	req, err := http.NewRequest("GET", cfg.APIRoot+"/agents", nil)
+	if err != nil {
+		return nil, err
+	}
+	if cfg.Authorize != nil {
+		cfg.Authorize(req)
+	}
+	resp, err := cfg.Client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != 200 {
+		return nil, fmt.Errorf("expected HTTP status code 200, got %d", resp.StatusCode)
+	}
+
+	var js struct {
+		Agents []Agent `json:"agents"`
+	}
+	err = json.NewDecoder(resp.Body).Decode(&js)
+	if err != nil {
+		return nil, err
+	}
+	agents := map[string]string{}
+	for _, a := range js.Agents {
+		agents[a.Name] = agentAddr(a)
+	}
+	return agents, nil
+}
+
+// agentAddr returns the host:port gRPC address of an agent,
+// whose address may be given as a URL.
+func agentAddr(a Agent) string {
+	host := a.Address
+	if u, err := url.Parse(a.Address); err == nil && u.Host != "" {
+		host = u.Hostname()
+	}
+	return net.JoinHostPort(host, strconv.Itoa(a.Port))
+}
+
+// Print writes a summary of the environment to w.
+func (env *Environment) Print(w io.Writer) {
+	tw := tabwriter.NewWriter(w, 8, 4, 1, ' ', 0)
+	for _, svc := range env.Services {
+		health := "ok"
+		if !svc.Healthy {
+			health = "UNHEALTHY"
+		}
+		version := svc.Version
+		if version == "" {
+			version = "unknown"
+		}
+		if svc.Build != "" {
+			version += " (" + svc.Build + ")"
+		}
+		fmt.Fprintf(tw, "  %s\t%s\t%s\t%s\t%s\n", svc.Name, svc.Address, health, version, svc.Detail)
+	}
+	tw.Flush()
+	if unknown := env.Unknown(); len(unknown) > 0 {
+		fmt.Fprintf(w, "  NOTE: versions under test are unknown for: %s\n", strings.Join(unknown, ", "))
+	}
+}
+
+// Unknown returns the names of the services whose version is
+// not known.
+func (env *Environment) Unknown() []string {
+	names := []string{}
+	for _, svc := range env.Services {
+		if svc.Version == "" {
+			names = append(names, svc.Name)
+		}
+	}
+	return names
+}
+
+// Healthy returns whether every service was healthy.
+func (env *Environment) Healthy() bool {
+	for _, svc := range env.Services {
+		if !svc.Healthy {
+			return false
+		}
+	}
+	return true
+}
+
+// Diff returns a line for each service whose version, build or
+// health differs between old and cur, or that is only in one.
+func Diff(old *Environment, cur *Environment) []string {
+	if old == nil || cur == nil {
+		return nil
+	}
+	oldByName := map[string]*Service{}
+	for _, svc := range old.Services {
+		oldByName[svc.Name] = svc
+	}
+	seen := map[string]bool{}
+
+	lines := []string{}
+	for _, svc := range cur.Services {
+		seen[svc.Name] = true
+		o, ok := oldByName[svc.Name]
+		if !ok {
+			lines = append(lines, fmt.Sprintf("%s: added", svc.Name))
+			continue
+		}
+		if o.Version != svc.Version || o.Build != svc.Build {
+			lines = append(lines, fmt.Sprintf("%s: version %q (%s) -> %q (%s)", svc.Name, o.Version, o.Build, svc.Version, svc.Build))
+		}
+		if o.Healthy != svc.Healthy {
+			lines = append(lines, fmt.Sprintf("%s: healthy %t -> %t", svc.Name, o.Healthy, svc.Healthy))
+		}
+	}
+	for _, svc := range old.Services {
+		if !seen[svc.Name] {
+			lines = append(lines, fmt.Sprintf("%s: removed", svc.Name))
+		}
+	}
+	return lines
+}
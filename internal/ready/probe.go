@@ -0,0 +1,45 @@
+// SPDX-License-Identifier: Apache-2.0 OR GPL-2.0-or-later
+
+package ready
+
+import (
+	"fmt"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"time"
+)
+
+// probeTimeout limits how long a single probe may take.
+const probeTimeout = 2 * time.Second
+
+// TCP returns a probe that succeeds once a TCP connection can be
+// made to addr, in host:port form. This is enough for services
+// such as the jobrunner and the agents, which speak gRPC.
+func TCP(addr string) func() error {
+	return func() error {
+		conn, err := net.DialTimeout("tcp", addr, probeTimeout)
+		if err != nil {
+			return err
+		}
+		return conn.Close()
+	}
+}
+
+// HTTP returns a probe that succeeds once a GET of url returns
+// the wanted status code.
+func HTTP(url string, code int) func() error {
+	client := &http.Client{Timeout: probeTimeout}
+	return func() error {
+		resp, err := client.Get(url)
+		if err != nil {
+			return err
+		}
+		defer resp.Body.Close()
+		b, _ := ioutil.ReadAll(resp.Body)
+		if resp.StatusCode != code {
+			return fmt.Errorf("expected HTTP status code %d, got %d: %s", code, resp.StatusCode, b)
+		}
+		return nil
+	}
+}
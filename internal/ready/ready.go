@@ -0,0 +1,112 @@
+// SPDX-License-Identifier: Apache-2.0 OR GPL-2.0-or-later
+
+// Package ready waits for the services that the tests depend
+// on to come up, and reports which of them never did.
+package ready
+
+import (
+	"fmt"
+	"io"
+	"sync"
+	"text/tabwriter"
+	"time"
+)
+
+// Dependency is a service that must be ready before testing.
+type Dependency struct {
+	// Name describes the service, e.g. "api (http://api:3005)".
+	Name string
+
+	// Probe returns nil if the service is ready, or an error
+	// describing why it is not.
+	Probe func() error
+}
+
+// Backoff controls the delay between probes of a dependency.
+// The delay starts at Initial and doubles after each failed
+// probe, up to Max.
+type Backoff struct {
+	Initial time.Duration
+	Max     time.Duration
+}
+
+// Status is the outcome of waiting for one dependency.
+type Status struct {
+	Name     string
+	Ready    bool
+	Attempts int
+	Elapsed  time.Duration
+
+	// Err is the error from the last probe, if the dependency
+	// never became ready.
+	Err error
+}
+
+// Wait probes each dependency, all at the same time, until it
+// is ready or until timeout elapses. It returns the status of
+// each dependency, in the same order.
+func Wait(deps []Dependency, timeout time.Duration, b Backoff) []*Status {
+	statuses := make([]*Status, len(deps))
+	var wg sync.WaitGroup
+	for i, d := range deps {
+		wg.Add(1)
+		go func(i int, d Dependency) {
+			defer wg.Done()
+			statuses[i] = waitOne(d, timeout, b)
+		}(i, d)
+	}
+	wg.Wait()
+	return statuses
+}
+
+// waitOne probes d until it is ready or until timeout elapses.
+func waitOne(d Dependency, timeout time.Duration, b Backoff) *Status {
+	st := &Status{Name: d.Name}
+	start := time.Now()
+	deadline := start.Add(timeout)
+	delay := b.Initial
+
+	for {
+		st.Attempts++
+		err := d.Probe()
+		st.Elapsed = time.Since(start)
+		if err == nil {
+			st.Ready = true
+			return st
+		}
+		st.Err = err
+
+		if time.Now().Add(delay).After(deadline) {
+			return st
+		}
+		time.Sleep(delay)
+		delay *= 2
+		if delay > b.Max {
+			delay = b.Max
+		}
+	}
+}
+
+// AllReady returns whether every dependency became ready.
+func AllReady(statuses []*Status) bool {
+	for _, st := range statuses {
+		if !st.Ready {
+			return false
+		}
+	}
+	return true
+}
+
+// Print writes a line for each dependency to w, saying how long
+// it took to become ready, or why it never did.
+func Print(w io.Writer, statuses []*Status) {
+	tw := tabwriter.NewWriter(w, 8, 4, 1, ' ', 0)
+	for _, st := range statuses {
+		if st.Ready {
+			fmt.Fprintf(tw, "  %s\tready after %s\t(%d attempts)\n", st.Name, st.Elapsed.Round(time.Millisecond), st.Attempts)
+		} else {
+			fmt.Fprintf(tw, "  %s\tNOT READY after %s\t(%d attempts): %v\n", st.Name, st.Elapsed.Round(time.Millisecond), st.Attempts, st.Err)
+		}
+	}
+	tw.Flush()
+}
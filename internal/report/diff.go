@@ -0,0 +1,91 @@
+// SPDX-License-Identifier: Apache-2.0 OR GPL-2.0-or-later
+
+package report
+
+import (
+	"bytes"
+	"encoding/json"
+	"strings"
+
+	"github.com/sergi/go-diff/diffmatchpatch"
+)
+
+// diffRow is one row of a side-by-side diff. Either side may be
+// blank, where a line was only added or only removed.
+type diffRow struct {
+	Left      string
+	Right     string
+	LeftKind  string
+	RightKind string
+}
+
+// prettyJSON returns b indented with sorted keys, so that
+// equivalent JSON values are printed identically. If b is not
+// JSON, it is returned unchanged.
+func prettyJSON(b []byte) string {
+	var v interface{}
+	if len(bytes.TrimSpace(b)) == 0 || json.Unmarshal(b, &v) != nil {
+		return string(b)
+	}
+	out, err := json.MarshalIndent(v, "", "  ")
+	if err != nil {
+		return string(b)
+	}
+	return string(out)
+}
+
+// splitLines splits s into lines, without their line endings.
+func splitLines(s string) []string {
+	s = strings.TrimSuffix(s, "\n")
+	if s == "" {
+		return nil
+	}
+	return strings.Split(s, "\n")
+}
+
+// sideBySide compares wanted and got line by line, after
+// pretty-printing them if they are JSON, and returns the rows of
+// a side-by-side diff. Runs of removed lines are paired up with
+// any immediately following runs of added lines.
+func sideBySide(wanted string, got []byte) []diffRow {
+	dmp := diffmatchpatch.New()
+	a, b, lines := dmp.DiffLinesToChars(prettyJSON([]byte(wanted))+"\n", prettyJSON(got)+"\n")
+	diffs := dmp.DiffCharsToLines(dmp.DiffMain(a, b, false), lines)
+
+	rows := []diffRow{}
+	removed := []string{}
+	flush := func(added []string) {
+		n := len(removed)
+		if len(added) > n {
+			n = len(added)
+		}
+		for i := 0; i < n; i++ {
+			row := diffRow{}
+			if i < len(removed) {
+				row.Left, row.LeftKind = removed[i], "del"
+			}
+			if i < len(added) {
+				row.Right, row.RightKind = added[i], "add"
+			}
+			rows = append(rows, row)
+		}
+		removed = nil
+	}
+
+	for _, d := range diffs {
+		switch d.Type {
+		case diffmatchpatch.DiffDelete:
+			removed = append(removed, splitLines(d.Text)...)
+		case diffmatchpatch.DiffInsert:
+			flush(splitLines(d.Text))
+		case diffmatchpatch.DiffEqual:
+			flush(nil)
+			for _, l := range splitLines(d.Text) {
+				rows = append(rows, diffRow{Left: l, Right: l})
+			}
+		}
+	}
+	flush(nil)
+
+	return rows
+}
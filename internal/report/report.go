@@ -0,0 +1,179 @@
+// SPDX-License-Identifier: Apache-2.0 OR GPL-2.0-or-later
+
+// Package report writes a self-contained HTML report of a run's
+// results, including a transcript of each test's requests.
+package report
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"time"
+
+	"github.com/swinslow/peridot-jobrunner-testing/internal/preflight"
+	"github.com/swinslow/peridot-jobrunner-testing/internal/testresult"
+)
+
+// exchangeView is one HTTP exchange, as shown in the report.
+type exchangeView struct {
+	Step        string
+	Method      string
+	URL         string
+	User        string
+	RequestBody string
+	StatusCode  int
+	Response    string
+	Duration    time.Duration
+
+	// BarLeft and BarWidth place the exchange's timing bar, as
+	// percentages of the time between the test's first request
+	// and its last response.
+	BarLeft  float64
+	BarWidth float64
+}
+
+// testView is one test's result, as shown in the report.
+type testView struct {
+	ID          string
+	Status      string
+	Success     bool
+	FailStep    string
+	FailError   string
+	Duration    time.Duration
+	Exchanges   []exchangeView
+	Transitions []testresult.JobTransition
+	Diagnostics []string
+	Diff        []diffRow
+}
+
+// hookView is one failed hook, as shown in the report.
+type hookView struct {
+	Suite   string
+	Hook    string
+	Test    string
+	Error   string
+	Skipped int
+}
+
+// elementView groups the tests for one Suite and Element.
+type elementView struct {
+	Suite   string
+	Element string
+	Failed  int
+	Tests   []*testView
+}
+
+// reportView holds everything shown in the report.
+type reportView struct {
+	Generated   time.Time
+	Environment *preflight.Environment
+	Total       int
+	Failed      int
+	Hooks       []hookView
+	Elements    []*elementView
+}
+
+// status returns the short status shown for a result.
+func status(r *testresult.TestResult) string {
+	switch {
+	case r.Success:
+		return "ok"
+	case r.Flaky:
+		return fmt.Sprintf("FLAKY (passed on attempt %d)", r.Attempts)
+	case r.Attempts > 1:
+		return fmt.Sprintf("FAIL (all %d attempts)", r.Attempts)
+	}
+	return "FAIL"
+}
+
+// newTestView builds the view of one result.
+func newTestView(r *testresult.TestResult) *testView {
+	tv := &testView{
+		ID:          r.ID,
+		Status:      status(r),
+		Success:     r.Success,
+		FailStep:    r.FailStep,
+		Transitions: r.Transitions,
+		Diagnostics: r.Diagnostics,
+	}
+	if r.FailError != nil {
+		tv.FailError = r.FailError.Error()
+	}
+	if !r.Success && r.Wanted != "" {
+		tv.Diff = sideBySide(r.Wanted, r.Got)
+	}
+
+	if len(r.Exchanges) == 0 {
+		return tv
+	}
+	first := r.Exchanges[0].Start
+	last := first
+	for _, ex := range r.Exchanges {
+		if end := ex.Start.Add(ex.Duration); end.After(last) {
+			last = end
+		}
+	}
+	tv.Duration = last.Sub(first)
+
+	for _, ex := range r.Exchanges {
+		ev := exchangeView{
+			Step:        ex.Step,
+			Method:      ex.Method,
+			URL:         ex.URL,
+			User:        ex.User,
+			RequestBody: prettyJSON([]byte(ex.RequestBody)),
+			StatusCode:  ex.StatusCode,
+			Response:    prettyJSON(ex.ResponseBody),
+			Duration:    ex.Duration,
+		}
+		if tv.Duration > 0 {
+			ev.BarLeft = 100 * float64(ex.Start.Sub(first)) / float64(tv.Duration)
+			ev.BarWidth = 100 * float64(ex.Duration) / float64(tv.Duration)
+		}
+		tv.Exchanges = append(tv.Exchanges, ev)
+	}
+
+	return tv
+}
+
+// Write writes the HTML report for the results and failed hooks
+// to w, along with the environment that they were run in, which
+// may be nil. Results are grouped by Suite and Element, in the
+// order that each Suite and Element first appears.
+func Write(w io.Writer, rs []*testresult.TestResult, hooks []*testresult.HookResult, env *preflight.Environment, generated time.Time) error {
+	rv := &reportView{Generated: generated, Environment: env, Total: len(rs)}
+	for _, h := range hooks {
+		rv.Hooks = append(rv.Hooks, hookView{Suite: h.Suite, Hook: h.Hook, Test: h.Test, Error: h.Err.Error(), Skipped: h.Skipped})
+	}
+	byKey := map[string]*elementView{}
+	for _, r := range rs {
+		key := r.Suite + ":" + r.Element
+		ev, ok := byKey[key]
+		if !ok {
+			ev = &elementView{Suite: r.Suite, Element: r.Element}
+			byKey[key] = ev
+			rv.Elements = append(rv.Elements, ev)
+		}
+		ev.Tests = append(ev.Tests, newTestView(r))
+		if !r.Success {
+			ev.Failed++
+			rv.Failed++
+		}
+	}
+
+	return reportTemplate.Execute(w, rv)
+}
+
+// WriteFile writes the HTML report for the results and failed
+// hooks to the file at path.
+func WriteFile(path string, rs []*testresult.TestResult, hooks []*testresult.HookResult, env *preflight.Environment, generated time.Time) error {
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	err = Write(f, rs, hooks, env, generated)
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	return err
+}
@@ -0,0 +1,99 @@
+// SPDX-License-Identifier: Apache-2.0 OR GPL-2.0-or-later
+
+package report
+
+import (
+	"fmt"
+	"html/template"
+)
+
+// reportTemplate is the HTML report. It is self-contained, with
+// inline styles and no scripts, so that it can be attached to a
+// bug report as a single file.
+var reportTemplate = template.Must(template.New("report").Funcs(template.FuncMap{
+	"pct": func(f float64) template.CSS { return template.CSS(fmt.Sprintf("%.2f%%", f)) },
+}).Parse(`<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<title>peridot-jobrunner-testing report</title>
+<style>
+body { font-family: sans-serif; margin: 2em; color: #222; }
+h2 { margin-top: 1.5em; border-bottom: 1px solid #ccc; }
+details { margin: 0.3em 0; }
+summary { cursor: pointer; padding: 0.2em; }
+.ok { color: #1a7f37; }
+.fail { color: #cf222e; font-weight: bold; }
+.test { margin-left: 1em; }
+.exchange { margin: 0.5em 0 0.5em 2em; border-left: 3px solid #ddd; padding-left: 0.5em; }
+pre { background: #f6f8fa; padding: 0.5em; overflow-x: auto; margin: 0.3em 0; }
+table.diff { border-collapse: collapse; width: 100%; font-family: monospace; font-size: 90%; }
+table.diff td { vertical-align: top; white-space: pre; padding: 0 0.5em; width: 50%; }
+table.diff th { text-align: left; }
+td.del { background: #ffebe9; }
+td.add { background: #e6ffec; }
+.bar { position: relative; height: 0.6em; background: #eee; width: 20em; display: inline-block; }
+.bar span { position: absolute; top: 0; bottom: 0; background: #0969da; min-width: 1px; }
+.meta { color: #666; }
+table.env { border-collapse: collapse; }
+table.env td, table.env th { text-align: left; padding: 0.1em 1em 0.1em 0; }
+</style>
+</head>
+<body>
+<h1>peridot-jobrunner-testing report</h1>
+<p class="meta">Generated {{.Generated.Format "2006-01-02 15:04:05 MST"}}: {{.Total}} tests, {{if .Failed}}<span class="fail">{{.Failed}} failed</span>{{else}}<span class="ok">all passed</span>{{end}}{{if .Hooks}}, <span class="fail">{{len .Hooks}} hooks failed</span>{{end}}</p>
+{{with .Environment}}{{with .Unknown}}
+<p class="fail">Versions under test are unknown for: {{range $i, $n := .}}{{if $i}}, {{end}}{{$n}}{{end}}. The results may not be comparable with other runs.</p>
+{{end}}{{end}}
+{{with .Environment}}
+<h2>Environment</h2>
+<table class="env">
+<tr><th>Service</th><th>Address</th><th>Health</th><th>Version</th><th>Build</th><th>Detail</th></tr>
+{{range .Services}}<tr><td>{{.Name}}</td><td>{{.Address}}</td><td>{{if .Healthy}}<span class="ok">ok</span>{{else}}<span class="fail">UNHEALTHY</span>{{end}}</td><td>{{if .Version}}{{.Version}}{{else}}unknown{{end}}</td><td>{{.Build}}</td><td class="meta">{{.Detail}}</td></tr>
+{{end}}</table>
+<p class="meta">Collected {{.Collected.Format "2006-01-02 15:04:05 MST"}}</p>
+{{end}}
+{{if .Hooks}}
+<h2>Hook failures <span class="fail">({{len .Hooks}})</span></h2>
+<table class="env">
+<tr><th>Suite</th><th>Hook</th><th>Test</th><th>Skipped</th><th>Error</th></tr>
+{{range .Hooks}}<tr><td>{{.Suite}}</td><td><span class="fail">{{.Hook}}</span></td><td>{{.Test}}</td><td>{{.Skipped}}</td><td>{{.Error}}</td></tr>
+{{end}}</table>
+{{end}}
+{{range .Elements}}
+<h2>{{.Suite}}: {{.Element}} {{if .Failed}}<span class="fail">({{.Failed}} failed)</span>{{end}}</h2>
+{{range .Tests}}
+<details class="test"{{if not .Success}} open{{end}}>
+<summary><span class="{{if .Success}}ok{{else}}fail{{end}}">{{.Status}}</span> {{.ID}} <span class="meta">{{.Duration}}</span></summary>
+{{if not .Success}}
+<p>Failed at step {{.FailStep}}{{if .FailError}}: {{.FailError}}{{end}}</p>
+{{if .Diff}}
+<table class="diff">
+<tr><th>Wanted</th><th>Got</th></tr>
+{{range .Diff}}<tr><td class="{{.LeftKind}}">{{.Left}}</td><td class="{{.RightKind}}">{{.Right}}</td></tr>
+{{end}}</table>
+{{end}}
+{{end}}
+{{range .Exchanges}}
+<details class="exchange">
+<summary>step {{.Step}}: {{.Method}} {{.URL}} ({{.User}}) &rarr; {{if .StatusCode}}{{.StatusCode}}{{else}}no response{{end}} <span class="bar"><span style="left: {{pct .BarLeft}}; width: {{pct .BarWidth}}"></span></span> <span class="meta">{{.Duration}}</span></summary>
+{{if .RequestBody}}<p>Request:</p><pre>{{.RequestBody}}</pre>{{end}}
+{{if .Response}}<p>Response:</p><pre>{{.Response}}</pre>{{end}}
+</details>
+{{end}}
+{{if .Transitions}}
+<p>Job transitions:</p>
+<pre>{{range .Transitions}}{{.At.Format "15:04:05.000"}}  job {{.JobID}}: {{.Status}} / {{.Health}}
+{{end}}</pre>
+{{end}}
+{{if .Diagnostics}}
+<p>Diagnostics:</p>
+<pre>{{range .Diagnostics}}{{.}}
+{{end}}</pre>
+{{end}}
+</details>
+{{end}}
+{{end}}
+</body>
+</html>
+`))
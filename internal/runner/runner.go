@@ -0,0 +1,288 @@
+// SPDX-License-Identifier: Apache-2.0 OR GPL-2.0-or-later
+
+// Package runner runs suites of registered tests, returning the
+// fixtures to their initial state before each test, and runs the
+// suites' hooks around them.
+package runner
+
+import (
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"runtime"
+
+	"github.com/swinslow/peridot-jobrunner-testing/internal/har"
+	"github.com/swinslow/peridot-jobrunner-testing/internal/testresult"
+	"github.com/swinslow/peridot-jobrunner-testing/test/utils"
+)
+
+// Fixture is something that a test may change, which can be
+// returned to its initial state, e.g. the DB or the volumes.
+type Fixture interface {
+	Reset() error
+}
+
+// Runner runs tests, resetting the DB and volumes to their
+// fixture state before each one, and runs their suites' hooks.
+type Runner struct {
+	// Root is the API root URL that is passed to each test and
+	// hook.
+	Root string
+
+	// DB is reset before each test.
+	DB Fixture
+
+	// Volumes, if not nil, is reset before each test, before DB.
+	// It is nil when replaying a recording, which has no
+	// volumes to reset.
+	Volumes Fixture
+
+	// Reruns is how many times to rerun a failing test.
+	Reruns int
+
+	// HAR, if not nil, records the HTTP traffic of each test
+	// and of the fixture resets and hooks around it.
+	HAR *har.Recorder
+
+	// HARDir, if not empty, is the directory in which to save
+	// a HAR file for each test.
+	HARDir string
+
+	// Pause, if not nil, is called when a test first fails,
+	// before its suite's AfterEach hook or any rerun, while the
+	// DB and volumes are as the test left them. It returns true
+	// to stop running tests.
+	Pause func(res *testresult.TestResult) bool
+
+	// Out, if not nil, is where the name of each test is
+	// written as it starts, along with any errors in saving
+	// HAR files.
+	Out io.Writer
+
+	// HAREntries holds all of the recorded traffic so far.
+	HAREntries []*har.Entry
+
+	// HookResults holds each hook or fixture reset that failed.
+	HookResults []*testresult.HookResult
+
+	// Stopped is set once Pause has asked to stop.
+	Stopped bool
+}
+
+// out returns where rn writes its progress.
+func (rn *Runner) out() io.Writer {
+	if rn.Out == nil {
+		return ioutil.Discard
+	}
+	return rn.Out
+}
+
+// funcName returns the name of a TestFunc, for progress output.
+func funcName(t testresult.TestFunc) string {
+	return runtime.FuncForPC(reflect.ValueOf(t).Pointer()).Name()
+}
+
+// testName returns the Suite:Element:ID name of a test that was
+// not run, found with a dry run of it.
+func testName(t testresult.TestFunc) string {
+	res, _ := utils.DryRunTest(t, "")
+	return res.Name()
+}
+
+// Run runs the tests in each of suites, between the suite's
+// BeforeAll and AfterAll hooks, and returns their results. A
+// suite's tests are not run if its BeforeAll hook fails. Tests
+// that were not run because of a failed hook or fixture reset
+// have no result; the failure is in rn.HookResults instead.
+func (rn *Runner) Run(suites []testresult.Suite) []*testresult.TestResult {
+	rs := []*testresult.TestResult{}
+	for _, s := range suites {
+		if rn.beforeAll(s) {
+			for _, t := range s.Tests {
+				fmt.Fprintf(rn.out(), "  %s\n", funcName(t.Func))
+				res := rn.run(s, t.Func)
+				if res == nil {
+					continue
+				}
+				rs = append(rs, res)
+				if rn.Stopped {
+					break
+				}
+			}
+		}
+		rn.afterAll(s)
+		if rn.Stopped {
+			break
+		}
+	}
+	return rs
+}
+
+// reset returns the volumes and DB to their fixture state.
+func (rn *Runner) reset() error {
+	if rn.Volumes != nil {
+		err := rn.Volumes.Reset()
+		if err != nil {
+			return fmt.Errorf("error resetting volume: %v", err)
+		}
+	}
+	err := rn.DB.Reset()
+	rn.TakeHAR("fixture reset")
+	if err != nil {
+		return fmt.Errorf("error resetting DB fixture: %v", err)
+	}
+	return nil
+}
+
+// TakeHAR labels the traffic recorded since the last call with
+// comment, and adds it to rn.HAREntries. It returns the entries
+// that it took.
+func (rn *Runner) TakeHAR(comment string) []*har.Entry {
+	if rn.HAR == nil {
+		return nil
+	}
+	entries := rn.HAR.Take()
+	for _, e := range entries {
+		e.Comment = comment
+	}
+	rn.HAREntries = append(rn.HAREntries, entries...)
+	return entries
+}
+
+// saveTestHAR saves the traffic for one test, including its
+// fixture resets, to a file in rn.HARDir.
+func (rn *Runner) saveTestHAR(res *testresult.TestResult, entries []*har.Entry) error {
+	if rn.HARDir == "" {
+		return nil
+	}
+	err := os.MkdirAll(rn.HARDir, 0755)
+	if err != nil {
+		return err
+	}
+	return har.Save(filepath.Join(rn.HARDir, har.FileName(res.Name())), entries)
+}
+
+// hookFailed records that hook, of the suite named suite, failed
+// with err, and that skipped tests were not run because of it.
+func (rn *Runner) hookFailed(suite string, hook string, test string, err error, skipped int) {
+	rn.HookResults = append(rn.HookResults, &testresult.HookResult{
+		Suite:   suite,
+		Hook:    hook,
+		Test:    test,
+		Err:     err,
+		Skipped: skipped,
+	})
+}
+
+// beforeAll runs s's BeforeAll hook, if any, and returns whether
+// it succeeded. If it fails, none of s's tests should be run.
+func (rn *Runner) beforeAll(s testresult.Suite) bool {
+	if s.Hooks.BeforeAll == nil {
+		return true
+	}
+	err := s.Hooks.BeforeAll(rn.Root)
+	rn.TakeHAR(s.Name + " BeforeAll")
+	if err != nil {
+		rn.hookFailed(s.Name, "BeforeAll", "", err, len(s.Tests))
+		return false
+	}
+	return true
+}
+
+// afterAll runs s's AfterAll hook, if any. It is run even if
+// s's BeforeAll hook failed, so that it can clean up after it.
+func (rn *Runner) afterAll(s testresult.Suite) {
+	if s.Hooks.AfterAll == nil {
+		return
+	}
+	err := s.Hooks.AfterAll(rn.Root)
+	rn.TakeHAR(s.Name + " AfterAll")
+	if err != nil {
+		rn.hookFailed(s.Name, "AfterAll", "", err, 0)
+	}
+}
+
+// run runs t, a test in suite s. If t fails, it is rerun up to
+// rn.Reruns times; if any rerun passes, the original failing
+// result is returned marked as flaky. If the fixtures could not
+// be reset before t, or s's BeforeEach hook failed, t is not
+// run and nil is returned; the failure is in rn.HookResults.
+func (rn *Runner) run(s testresult.Suite, t testresult.TestFunc) *testresult.TestResult {
+	start := len(rn.HAREntries)
+
+	res := rn.attempt(s, t, 1)
+	if res == nil {
+		return nil
+	}
+	res.Attempts = 1
+
+	for i := 0; i < rn.Reruns && !res.Success && !rn.Stopped; i++ {
+		rerun := rn.attempt(s, t, res.Attempts+1)
+		if rerun == nil {
+			break
+		}
+		res.Attempts++
+		if rerun.Success {
+			res.Flaky = true
+			break
+		}
+	}
+
+	err := rn.saveTestHAR(res, rn.HAREntries[start:])
+	if err != nil {
+		fmt.Fprintf(rn.out(), "Error saving HAR file for %s: %v\n", res.Name(), err)
+	}
+
+	return res
+}
+
+// attempt resets the fixtures and runs t, between s's BeforeEach
+// and AfterEach hooks, pausing if the first attempt fails. n is
+// the number of the attempt, starting from 1. If the reset or
+// the BeforeEach hook fails, t is not run, nor is the AfterEach
+// hook, and nil is returned.
+func (rn *Runner) attempt(s testresult.Suite, t testresult.TestFunc, n int) *testresult.TestResult {
+	// only a failure before the first attempt skips the test
+	skipped := 0
+	if n == 1 {
+		skipped = 1
+	}
+
+	err := rn.reset()
+	if err != nil {
+		rn.hookFailed(s.Name, "fixture reset", testName(t), err, skipped)
+		return nil
+	}
+	if s.Hooks.BeforeEach != nil {
+		err = s.Hooks.BeforeEach(rn.Root)
+		rn.TakeHAR(s.Name + " BeforeEach")
+		if err != nil {
+			rn.hookFailed(s.Name, "BeforeEach", testName(t), err, skipped)
+			return nil
+		}
+	}
+
+	res := t(rn.Root)
+	if n == 1 {
+		rn.TakeHAR(res.Name())
+	} else {
+		rn.TakeHAR(fmt.Sprintf("%s (attempt %d)", res.Name(), n))
+	}
+
+	if n == 1 && !res.Success && rn.Pause != nil {
+		rn.Stopped = rn.Pause(res)
+	}
+
+	if s.Hooks.AfterEach != nil {
+		err = s.Hooks.AfterEach(rn.Root, res)
+		rn.TakeHAR(s.Name + " AfterEach")
+		if err != nil {
+			rn.hookFailed(s.Name, "AfterEach", res.Name(), err, 0)
+		}
+	}
+
+	return res
+}
@@ -0,0 +1,301 @@
+// SPDX-License-Identifier: Apache-2.0 OR GPL-2.0-or-later
+
+package runner
+
+import (
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/swinslow/peridot-jobrunner-testing/internal/testresult"
+)
+
+// calls logs what the tests, hooks and fixtures in a run did,
+// in order.
+type calls []string
+
+func (c *calls) add(s string) {
+	*c = append(*c, s)
+}
+
+// fixture is a Fixture that logs each reset, and fails the
+// resets whose numbers, starting from 1, are in fail.
+type fixture struct {
+	name string
+	log  *calls
+	n    int
+	fail map[int]bool
+}
+
+func (f *fixture) Reset() error {
+	f.n++
+	f.log.add(f.name + " reset")
+	if f.fail[f.n] {
+		return errors.New(f.name + " is broken")
+	}
+	return nil
+}
+
+// test returns a test named id that logs each run, and passes
+// on the runs whose numbers, starting from 1, are in pass.
+func test(log *calls, id string, pass ...int) testresult.TestFunc {
+	n := 0
+	return func(root string) *testresult.TestResult {
+		res := &testresult.TestResult{Suite: "s", Element: "e", ID: id}
+		if root == "" {
+			// a dry run to find the test's name
+			return res
+		}
+		n++
+		log.add(id)
+		for _, p := range pass {
+			if p == n {
+				res.Success = true
+			}
+		}
+		return res
+	}
+}
+
+// hooks returns hooks that log each call, and fail if named in
+// fail.
+func hooks(log *calls, fail ...string) testresult.Hooks {
+	failing := map[string]bool{}
+	for _, f := range fail {
+		failing[f] = true
+	}
+	hook := func(name string) error {
+		log.add(name)
+		if failing[name] {
+			return errors.New(name + " failed")
+		}
+		return nil
+	}
+	return testresult.Hooks{
+		BeforeAll:  func(root string) error { return hook("BeforeAll") },
+		AfterAll:   func(root string) error { return hook("AfterAll") },
+		BeforeEach: func(root string) error { return hook("BeforeEach") },
+		AfterEach: func(root string, res *testresult.TestResult) error {
+			return hook("AfterEach")
+		},
+	}
+}
+
+// newRunner returns a Runner whose DB fixture logs to log.
+func newRunner(log *calls) *Runner {
+	return &Runner{Root: "http://api", DB: &fixture{name: "db", log: log}}
+}
+
+// names returns the names of rs.
+func names(rs []*testresult.TestResult) []string {
+	ns := []string{}
+	for _, r := range rs {
+		ns = append(ns, r.Name())
+	}
+	return ns
+}
+
+// checkHooks checks that rn recorded exactly the wanted hook
+// failures, ignoring their errors' text.
+func checkHooks(t *testing.T, rn *Runner, want []testresult.HookResult) {
+	t.Helper()
+	if len(rn.HookResults) != len(want) {
+		t.Fatalf("got %d hook results %+v, wanted %d", len(rn.HookResults), rn.HookResults, len(want))
+	}
+	for i, h := range rn.HookResults {
+		if h.Err == nil {
+			t.Errorf("hook result %d: got no error", i)
+		}
+		got := *h
+		got.Err = nil
+		if got != want[i] {
+			t.Errorf("hook result %d: got %+v, wanted %+v", i, got, want[i])
+		}
+	}
+}
+
+func TestRunHooksInOrder(t *testing.T) {
+	log := &calls{}
+	rn := newRunner(log)
+	rs := rn.Run([]testresult.Suite{
+		{Name: "s", Tests: []testresult.Test{{Func: test(log, "a", 1)}, {Func: test(log, "b", 1)}}, Hooks: hooks(log)},
+	})
+
+	want := calls{"BeforeAll", "db reset", "BeforeEach", "a", "AfterEach", "db reset", "BeforeEach", "b", "AfterEach", "AfterAll"}
+	if !reflect.DeepEqual(*log, want) {
+		t.Errorf("got calls %v, wanted %v", *log, want)
+	}
+	if got := names(rs); !reflect.DeepEqual(got, []string{"s:e:a", "s:e:b"}) {
+		t.Errorf("got results %v", got)
+	}
+	checkHooks(t, rn, nil)
+}
+
+func TestRunBeforeAllFails(t *testing.T) {
+	log := &calls{}
+	rn := newRunner(log)
+	rs := rn.Run([]testresult.Suite{
+		{Name: "broken", Tests: []testresult.Test{{Func: test(log, "a", 1)}, {Func: test(log, "b", 1)}}, Hooks: hooks(log, "BeforeAll")},
+		{Name: "next", Tests: []testresult.Test{{Func: test(log, "c", 1)}}},
+	})
+
+	// none of the suite's tests run, but its AfterAll hook does,
+	// and so does the next suite
+	want := calls{"BeforeAll", "AfterAll", "db reset", "c"}
+	if !reflect.DeepEqual(*log, want) {
+		t.Errorf("got calls %v, wanted %v", *log, want)
+	}
+	if got := names(rs); !reflect.DeepEqual(got, []string{"s:e:c"}) {
+		t.Errorf("got results %v, wanted only s:e:c", got)
+	}
+	checkHooks(t, rn, []testresult.HookResult{{Suite: "broken", Hook: "BeforeAll", Skipped: 2}})
+}
+
+func TestRunAfterAllFails(t *testing.T) {
+	log := &calls{}
+	rn := newRunner(log)
+	rs := rn.Run([]testresult.Suite{
+		{Name: "s", Tests: []testresult.Test{{Func: test(log, "a", 1)}}, Hooks: hooks(log, "AfterAll")},
+	})
+
+	if got := names(rs); !reflect.DeepEqual(got, []string{"s:e:a"}) || !rs[0].Success {
+		t.Errorf("got results %v, wanted s:e:a to pass", got)
+	}
+	checkHooks(t, rn, []testresult.HookResult{{Suite: "s", Hook: "AfterAll"}})
+}
+
+func TestRunBeforeEachFails(t *testing.T) {
+	log := &calls{}
+	rn := newRunner(log)
+	rs := rn.Run([]testresult.Suite{
+		{Name: "s", Tests: []testresult.Test{{Func: test(log, "a", 1)}, {Func: test(log, "b", 1)}}, Hooks: hooks(log, "BeforeEach")},
+	})
+
+	// neither test nor AfterEach runs after a failed BeforeEach
+	want := calls{"BeforeAll", "db reset", "BeforeEach", "db reset", "BeforeEach", "AfterAll"}
+	if !reflect.DeepEqual(*log, want) {
+		t.Errorf("got calls %v, wanted %v", *log, want)
+	}
+	if len(rs) != 0 {
+		t.Errorf("got results %v, wanted none", names(rs))
+	}
+	checkHooks(t, rn, []testresult.HookResult{
+		{Suite: "s", Hook: "BeforeEach", Test: "s:e:a", Skipped: 1},
+		{Suite: "s", Hook: "BeforeEach", Test: "s:e:b", Skipped: 1},
+	})
+}
+
+func TestRunAfterEachFails(t *testing.T) {
+	log := &calls{}
+	rn := newRunner(log)
+	rs := rn.Run([]testresult.Suite{
+		{Name: "s", Tests: []testresult.Test{{Func: test(log, "a")}}, Hooks: hooks(log, "AfterEach")},
+	})
+
+	// the test's own result is kept
+	if got := names(rs); !reflect.DeepEqual(got, []string{"s:e:a"}) || rs[0].Success {
+		t.Errorf("got results %v, wanted s:e:a to fail", got)
+	}
+	checkHooks(t, rn, []testresult.HookResult{{Suite: "s", Hook: "AfterEach", Test: "s:e:a"}})
+}
+
+func TestRunFixtureResetFails(t *testing.T) {
+	log := &calls{}
+	rn := newRunner(log)
+	rn.DB = &fixture{name: "db", log: log, fail: map[int]bool{1: true}}
+	rn.Volumes = &fixture{name: "volumes", log: log, fail: map[int]bool{2: true}}
+	rs := rn.Run([]testresult.Suite{
+		{Name: "s", Tests: []testresult.Test{{Func: test(log, "a", 1)}, {Func: test(log, "b", 1)}, {Func: test(log, "c", 1)}}},
+	})
+
+	want := calls{"volumes reset", "db reset", "volumes reset", "volumes reset", "db reset", "c"}
+	if !reflect.DeepEqual(*log, want) {
+		t.Errorf("got calls %v, wanted %v", *log, want)
+	}
+	if got := names(rs); !reflect.DeepEqual(got, []string{"s:e:c"}) {
+		t.Errorf("got results %v, wanted only s:e:c", got)
+	}
+	checkHooks(t, rn, []testresult.HookResult{
+		{Suite: "s", Hook: "fixture reset", Test: "s:e:a", Skipped: 1},
+		{Suite: "s", Hook: "fixture reset", Test: "s:e:b", Skipped: 1},
+	})
+	if !strings.Contains(rn.HookResults[0].Err.Error(), "DB fixture") || !strings.Contains(rn.HookResults[1].Err.Error(), "volume") {
+		t.Errorf("got errors %q and %q, wanted DB then volume", rn.HookResults[0].Err, rn.HookResults[1].Err)
+	}
+}
+
+func TestRunRerunBeforeEachFails(t *testing.T) {
+	log := &calls{}
+	rn := newRunner(log)
+	rn.Reruns = 2
+	failSecond := 0
+	h := testresult.Hooks{BeforeEach: func(root string) error {
+		failSecond++
+		if failSecond == 2 {
+			return errors.New("BeforeEach failed")
+		}
+		return nil
+	}}
+	rs := rn.Run([]testresult.Suite{
+		{Name: "s", Tests: []testresult.Test{{Func: test(log, "a")}}, Hooks: h},
+	})
+
+	// a failure before a rerun does not skip the test, which
+	// keeps its first result
+	if len(rs) != 1 || rs[0].Attempts != 1 || rs[0].Success {
+		t.Fatalf("got results %+v, wanted one failure after 1 attempt", rs)
+	}
+	checkHooks(t, rn, []testresult.HookResult{{Suite: "s", Hook: "BeforeEach", Test: "s:e:a"}})
+}
+
+func TestRunReruns(t *testing.T) {
+	log := &calls{}
+	rn := newRunner(log)
+	rn.Reruns = 2
+	rs := rn.Run([]testresult.Suite{
+		{Name: "s", Tests: []testresult.Test{{Func: test(log, "flaky", 2)}, {Func: test(log, "broken")}}},
+	})
+
+	if len(rs) != 2 {
+		t.Fatalf("got results %v, wanted 2", names(rs))
+	}
+	if rs[0].Success || !rs[0].Flaky || rs[0].Attempts != 2 {
+		t.Errorf("flaky: got success %t, flaky %t, %d attempts", rs[0].Success, rs[0].Flaky, rs[0].Attempts)
+	}
+	if rs[1].Success || rs[1].Flaky || rs[1].Attempts != 3 {
+		t.Errorf("broken: got success %t, flaky %t, %d attempts", rs[1].Success, rs[1].Flaky, rs[1].Attempts)
+	}
+}
+
+func TestRunPause(t *testing.T) {
+	for _, stop := range []bool{false, true} {
+		log := &calls{}
+		rn := newRunner(log)
+		rn.Reruns = 1
+		rn.Pause = func(res *testresult.TestResult) bool {
+			log.add("pause " + res.ID)
+			return stop
+		}
+		h := hooks(log)
+		h.BeforeAll, h.BeforeEach = nil, nil
+		rs := rn.Run([]testresult.Suite{
+			{Name: "s", Tests: []testresult.Test{{Func: test(log, "a")}, {Func: test(log, "b", 1)}}, Hooks: h},
+		})
+
+		// the pause comes before AfterEach and any rerun, and
+		// only after the first failure
+		want := calls{"db reset", "a", "pause a", "AfterEach", "db reset", "a", "AfterEach", "db reset", "b", "AfterEach", "AfterAll"}
+		wantRs := 2
+		if stop {
+			want = calls{"db reset", "a", "pause a", "AfterEach", "AfterAll"}
+			wantRs = 1
+		}
+		if !reflect.DeepEqual(*log, want) {
+			t.Errorf("stop %t: got calls %v, wanted %v", stop, *log, want)
+		}
+		if len(rs) != wantRs || rn.Stopped != stop {
+			t.Errorf("stop %t: got results %v, stopped %t", stop, names(rs), rn.Stopped)
+		}
+	}
+}
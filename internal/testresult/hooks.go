@@ -0,0 +1,58 @@
+// SPDX-License-Identifier: Apache-2.0 OR GPL-2.0-or-later
+
+package testresult
+
+// Hooks are functions that a suite registers to run around its
+// tests. Any of them may be nil. BeforeAll runs once before the
+// suite's first test, and AfterAll once after its last. The
+// fixtures are reset before each test, so anything that a test
+// needs in the DB or volumes belongs in BeforeEach, which runs
+// after each reset. AfterEach runs after each attempt at a test,
+// and is given its result, e.g. to collect diagnostics if it
+// failed.
+type Hooks struct {
+	BeforeAll  func(root string) error
+	AfterAll   func(root string) error
+	BeforeEach func(root string) error
+	AfterEach  func(root string, res *TestResult) error
+}
+
+// Suite is a group of registered tests that share hooks.
+type Suite struct {
+	Name  string
+	Tests []Test
+	Hooks Hooks
+}
+
+// HookResult records a hook that failed. A failed fixture reset
+// before a test is recorded in the same way, with Hook set to
+// "fixture reset".
+type HookResult struct {
+	// Suite is the name of the suite that registered the hook.
+	Suite string
+
+	// Hook is the hook that failed, e.g. "BeforeAll".
+	Hook string
+
+	// Test identifies the test that a per-test hook ran
+	// around. It is empty for BeforeAll and AfterAll.
+	Test string
+
+	// Err is the error that the hook returned.
+	Err error
+
+	// Skipped is the number of tests that were not run
+	// because the hook failed.
+	Skipped int
+}
+
+// Name returns the Suite:Hook name of the hook, followed by the
+// test that it ran around, if any, e.g.
+// "scheduling:BeforeEach (jobrunner:scheduling:chain)".
+func (h *HookResult) Name() string {
+	name := h.Suite + ":" + h.Hook
+	if h.Test != "" {
+		name += " (" + h.Test + ")"
+	}
+	return name
+}
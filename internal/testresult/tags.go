@@ -0,0 +1,84 @@
+// SPDX-License-Identifier: Apache-2.0 OR GPL-2.0-or-later
+
+package testresult
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Test is a registered test, with the tags that are used to
+// select it, e.g. "readonly", "auth", "jobs", "slow",
+// "destructive" or "agent:nop". Unlike a TestResult's Suite
+// and Element, tags are known before the test runs.
+type Test struct {
+	Func TestFunc
+	Tags []string
+}
+
+// HasTag returns whether the test has the given tag.
+func (t Test) HasTag(tag string) bool {
+	for _, tt := range t.Tags {
+		if tt == tag {
+			return true
+		}
+	}
+	return false
+}
+
+// TagExpr is a parsed tag expression. It is a comma-separated
+// list of alternatives, any of which may match. Each alternative
+// is a "+"-separated list of tags, all of which must match; a tag
+// prefixed with "!" matches tests that do not have it. For
+// example, "readonly,jobs+!slow" matches tests that are tagged
+// readonly, and tests that are tagged jobs but not slow.
+type TagExpr struct {
+	alternatives [][]tagTerm
+}
+
+// tagTerm is one tag in an alternative.
+type tagTerm struct {
+	tag    string
+	negate bool
+}
+
+// ParseTagExpr parses a tag expression. An empty expression
+// parses to nil.
+func ParseTagExpr(s string) (*TagExpr, error) {
+	if strings.TrimSpace(s) == "" {
+		return nil, nil
+	}
+
+	e := &TagExpr{}
+	for _, alt := range strings.Split(s, ",") {
+		terms := []tagTerm{}
+		for _, term := range strings.Split(alt, "+") {
+			term = strings.TrimSpace(term)
+			t := tagTerm{tag: strings.TrimPrefix(term, "!")}
+			t.negate = t.tag != term
+			if t.tag == "" || strings.ContainsAny(t.tag, "! ") {
+				return nil, fmt.Errorf("invalid tag %q in tag expression %q", term, s)
+			}
+			terms = append(terms, t)
+		}
+		e.alternatives = append(e.alternatives, terms)
+	}
+	return e, nil
+}
+
+// Match returns whether the test's tags match the expression.
+func (e *TagExpr) Match(t Test) bool {
+	for _, alt := range e.alternatives {
+		matched := true
+		for _, term := range alt {
+			if t.HasTag(term.tag) == term.negate {
+				matched = false
+				break
+			}
+		}
+		if matched {
+			return true
+		}
+	}
+	return false
+}
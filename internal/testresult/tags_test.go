@@ -0,0 +1,90 @@
+// SPDX-License-Identifier: Apache-2.0 OR GPL-2.0-or-later
+
+package testresult
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestParseTagExprErrors(t *testing.T) {
+	tests := []string{
+		",",
+		"readonly,",
+		"+readonly",
+		"jobs++slow",
+		"!",
+		"!!slow",
+		"jobs+!",
+		"agent nop",
+		"jobs+sl!ow",
+	}
+
+	for _, s := range tests {
+		e, err := ParseTagExpr(s)
+		if err == nil {
+			t.Errorf("%q: got %+v, wanted error", s, e)
+			continue
+		}
+		if !strings.Contains(err.Error(), "invalid tag") {
+			t.Errorf("%q: got error %q, wanted invalid tag", s, err.Error())
+		}
+	}
+}
+
+func TestParseTagExprEmpty(t *testing.T) {
+	for _, s := range []string{"", "  "} {
+		e, err := ParseTagExpr(s)
+		if err != nil || e != nil {
+			t.Errorf("%q: got %+v, %v, wanted nil, nil", s, e, err)
+		}
+	}
+}
+
+func TestTagExprMatch(t *testing.T) {
+	readonly := Test{Tags: []string{"readonly", "jobs"}}
+	slowJob := Test{Tags: []string{"destructive", "jobs", "slow", "agent:nop"}}
+	fastJob := Test{Tags: []string{"destructive", "jobs"}}
+	untagged := Test{}
+
+	tests := []struct {
+		expr string
+		test Test
+		want bool
+	}{
+		{"readonly", readonly, true},
+		{"readonly", fastJob, false},
+		{"readonly", untagged, false},
+		{"!readonly", untagged, true},
+		{"!readonly", readonly, false},
+		{"agent:nop", slowJob, true},
+		{" jobs + slow ", slowJob, true},
+		{"jobs+slow", fastJob, false},
+		{"jobs+!slow", fastJob, true},
+		{"jobs+!slow", slowJob, false},
+		{"readonly,slow", readonly, true},
+		{"readonly,slow", slowJob, true},
+		{"readonly,slow", fastJob, false},
+
+		// "+" binds tighter than ",": this is readonly, or
+		// (jobs and not slow), not (readonly or jobs) and not slow
+		{"readonly,jobs+!slow", readonly, true},
+		{"readonly,jobs+!slow", fastJob, true},
+		{"readonly,jobs+!slow", slowJob, false},
+		{"slow,jobs+readonly", slowJob, true},
+		{"slow,jobs+readonly", fastJob, false},
+		{"!jobs,slow", untagged, true},
+		{"!jobs,slow", fastJob, false},
+	}
+
+	for _, tt := range tests {
+		e, err := ParseTagExpr(tt.expr)
+		if err != nil {
+			t.Errorf("%q: got error %v", tt.expr, err)
+			continue
+		}
+		if got := e.Match(tt.test); got != tt.want {
+			t.Errorf("%q on %v: got %t, wanted %t", tt.expr, tt.test.Tags, got, tt.want)
+		}
+	}
+}
@@ -2,6 +2,8 @@
 
 package testresult
 
+import "time"
+
 // TestResult contains data on the test, identifying it
 // and whether it succeeded or failed.
 type TestResult struct {
@@ -30,6 +32,80 @@ type TestResult struct {
 
 	// Got holds the latest JSON byte slice that was received.
 	Got []byte
+
+	// Attempts is the number of times the test was run,
+	// including any reruns after a failure.
+	Attempts int
+
+	// Flaky indicates that the test failed at first, but
+	// passed on at least one rerun.
+	Flaky bool
+
+	// Exchanges holds each HTTP request that the test made,
+	// with its response, in order.
+	Exchanges []Exchange
+
+	// Transitions holds each change in a job's status or
+	// health that was observed while waiting for the job.
+	Transitions []JobTransition
+
+	// Diagnostics holds extra lines of output that explain
+	// a failure, e.g. as collected by an AfterEach hook.
+	Diagnostics []string
+}
+
+// Name returns the test's Suite:Element:ID name.
+func (r *TestResult) Name() string {
+	return r.Suite + ":" + r.Element + ":" + r.ID
+}
+
+// Exchange records one HTTP request made by a test, and the
+// response that it received.
+type Exchange struct {
+	// Step is the test step that made the request.
+	Step string
+
+	// Method is the HTTP method, e.g. "GET".
+	Method string
+
+	// URL is the full request URL.
+	URL string
+
+	// User is the github username that the request was
+	// authenticated as, or "none".
+	User string
+
+	// RequestBody is the request body, if any.
+	RequestBody string
+
+	// StatusCode is the HTTP status code of the response, or
+	// zero if no response was received.
+	StatusCode int
+
+	// ResponseBody is the response body, if any.
+	ResponseBody []byte
+
+	// Start is when the request was sent.
+	Start time.Time
+
+	// Duration is how long it took to receive the full response.
+	Duration time.Duration
+}
+
+// JobTransition records a job's status and health when a
+// change in either was observed.
+type JobTransition struct {
+	// JobID is the ID of the job that was polled.
+	JobID uint32
+
+	// At is when the change was observed.
+	At time.Time
+
+	// Status is the job's new status, e.g. "running".
+	Status string
+
+	// Health is the job's new health, e.g. "ok".
+	Health string
 }
 
 // TestFunc defines a function that takes a string with the
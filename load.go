@@ -0,0 +1,80 @@
+// SPDX-License-Identifier: Apache-2.0 OR GPL-2.0-or-later
+
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/swinslow/peridot-jobrunner-testing/fixtures"
+	"github.com/swinslow/peridot-jobrunner-testing/internal/load"
+)
+
+// runLoad implements the load subcommand, which replays request
+// scenarios against the API and reports on their performance.
+// It returns the process exit code.
+func runLoad(args []string) int {
+	fs := flag.NewFlagSet("load", flag.ExitOnError)
+	apiRoot := fs.String("api", "http://api:3005", "root URL of the peridot API")
+	scenarios := fs.String("scenarios", strings.Join(load.ScenarioNames(), ","), "comma-separated scenarios to replay")
+	rate := fs.Float64("rate", 50, "requests per second to start across all workers; 0 for as fast as possible")
+	concurrency := fs.Int("concurrency", 4, "maximum number of requests in flight")
+	duration := fs.Duration("duration", 30*time.Second, "how long to run")
+	timeout := fs.Duration("timeout", 10*time.Second, "per-request timeout")
+	setup := fs.Bool("setup", true, "reset the DB to the fixture state before starting")
+	maxErrorRate := fs.Float64("max-error-rate", load.DefaultMaxErrorRate, "exit with failure if any scenario's error rate is above this fraction")
+	maxP99 := fs.Duration("max-p99", 0, "exit with failure if any scenario's p99 latency is above this; 0 to disable")
+	fs.Parse(args)
+
+	cfg := load.Config{
+		Root:        *apiRoot,
+		Rate:        *rate,
+		Concurrency: *concurrency,
+		Duration:    *duration,
+		Timeout:     *timeout,
+	}
+	for _, name := range strings.Split(*scenarios, ",") {
+		s := load.FindScenario(strings.TrimSpace(name))
+		if s == nil {
+			fmt.Printf("Unknown scenario %q; available: %s\n", name, strings.Join(load.ScenarioNames(), ", "))
+			return 2
+		}
+		cfg.Scenarios = append(cfg.Scenarios, s)
+	}
+
+	if *setup {
+		err := fixtures.NewDBFixture(*apiRoot, nil).Reset()
+		if err != nil {
+			fmt.Printf("Error resetting DB fixture before load run: %v\n", err)
+			return 1
+		}
+	}
+
+	fmt.Printf("Load testing for %s at %.1f req/s with concurrency %d\n\n", cfg.Duration, cfg.Rate, cfg.Concurrency)
+	rep, err := load.Run(cfg)
+	if err != nil {
+		fmt.Printf("Error running load test: %v\n", err)
+		return 1
+	}
+	rep.Print(os.Stdout)
+
+	failed := false
+	for _, st := range rep.Stats {
+		if st.ErrorRate() > *maxErrorRate {
+			fmt.Printf("\n%s: error rate %.2f%% is above %.2f%%", st.Scenario.Name, 100*st.ErrorRate(), 100**maxErrorRate)
+			failed = true
+		}
+		if *maxP99 > 0 && st.Percentile(99) > *maxP99 {
+			fmt.Printf("\n%s: p99 latency %s is above %s", st.Scenario.Name, st.Percentile(99), *maxP99)
+			failed = true
+		}
+	}
+	if failed {
+		fmt.Printf("\n")
+		return 1
+	}
+	return 0
+}
@@ -3,86 +3,215 @@
 package main
 
 import (
+	"flag"
 	"fmt"
 	"os"
-	"reflect"
-	"runtime"
-	"text/tabwriter"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"time"
 
 	"github.com/swinslow/peridot-jobrunner-testing/fixtures"
 	"github.com/swinslow/peridot-jobrunner-testing/internal/testresult"
 	"github.com/swinslow/peridot-jobrunner-testing/test/agents"
+	"github.com/swinslow/peridot-jobrunner-testing/test/concurrency"
+	"github.com/swinslow/peridot-jobrunner-testing/test/fuzz"
+	"github.com/swinslow/peridot-jobrunner-testing/test/jobconfig"
+	"github.com/swinslow/peridot-jobrunner-testing/test/property"
+	"github.com/swinslow/peridot-jobrunner-testing/test/scheduling"
+	"github.com/swinslow/peridot-jobrunner-testing/test/utils"
+	"github.com/swinslow/peridot-jobrunner-testing/test/volumes"
 )
 
+const usage = `Usage: peridot-jobrunner-testing [command] [flags]
+
+Commands:
+  run                  run the tests (the default if no command is given)
+  list                 list the registered tests
+  explain <pattern>    print the steps that matching tests will perform
+  fixtures setup       reset the DB and volumes to the fixture state
+  fixtures reset       reset the DB and volumes to an empty state
+  load                 load test the API
+
+Run "peridot-jobrunner-testing <command> -h" for a command's flags.
+`
+
 func main() {
-	apiRoot := "http://api:3005"
-	anyFailed := false
+	cmd, args := "run", os.Args[1:]
+	if len(args) > 0 && !strings.HasPrefix(args[0], "-") {
+		cmd, args = args[0], args[1:]
+	}
 
-	allRs := []*testresult.TestResult{}
-	var rs *testresult.TestResult
+	switch cmd {
+	case "run":
+		os.Exit(runTests(args))
+	case "list":
+		os.Exit(runList(args))
+	case "explain":
+		os.Exit(runExplain(args))
+	case "fixtures":
+		os.Exit(runFixtures(args))
+	case "load":
+		os.Exit(runLoad(args))
+	case "help":
+		fmt.Print(usage)
+	default:
+		fmt.Printf("Unknown command %q\n\n%s", cmd, usage)
+		os.Exit(2)
+	}
+}
 
-	// get all test suites
-	allTests := agents.GetTests()
+// envFlags describes where to find the services and volumes
+// under test.
+type envFlags struct {
+	apiRoot *string
+	dbHost  *string
+	dbPort  *int
+	dbUser  *string
+	dbName  *string
+	codeDir *string
+	spdxDir *string
+	seedDir *string
+}
 
-	// and run them, resetting DB and volume each time
-	fmt.Printf("Testing (%d total): \n", len(allTests))
-	for _, t := range allTests {
-		fmt.Printf("  %s\n", runtime.FuncForPC(reflect.ValueOf(t).Pointer()).Name())
-		err := fixtures.ResetVolume()
-		if err != nil {
-			fmt.Printf("Error resetting volume before test: %v\n", err)
-			os.Exit(1)
-		}
-		err = fixtures.ResetDB(apiRoot)
-		if err != nil {
-			fmt.Printf("Error resetting DB before test: %v\n", err)
-			os.Exit(1)
-		}
-		err = fixtures.SetupFixture(apiRoot)
-		if err != nil {
-			fmt.Printf("Error setting fixtures before test: %v\n", err)
-			os.Exit(1)
-		}
+// addEnvFlags adds the flags for the environment to fs.
+func addEnvFlags(fs *flag.FlagSet) *envFlags {
+	return &envFlags{
+		apiRoot: fs.String("api", "http://api:3005", "root URL of the peridot API"),
+		dbHost:  fs.String("db-host", "db", "postgres host, for DB snapshots"),
+		dbPort:  fs.Int("db-port", 5432, "postgres port, for DB snapshots"),
+		dbUser:  fs.String("db-user", "postgres-dev", "postgres user, for DB snapshots"),
+		dbName:  fs.String("db-name", "dev", "postgres database name, for DB snapshots"),
+		codeDir: fs.String("code-dir", "/code", "directory where the code volume is mounted"),
+		spdxDir: fs.String("spdx-dir", "/spdx", "directory where the spdx volume is mounted"),
+		seedDir: fs.String("seed-dir", "fixtures/testdata/volumes", "directory containing the code and spdx volume fixture trees"),
+	}
+}
 
-		rs = t(apiRoot)
-		allRs = append(allRs, rs)
+// pgConfig returns the postgres connection details.
+func (ef *envFlags) pgConfig() *fixtures.PGConfig {
+	return &fixtures.PGConfig{
+		Host:     *ef.dbHost,
+		Port:     *ef.dbPort,
+		User:     *ef.dbUser,
+		Password: os.Getenv("PGPASSWORD"),
+		DBName:   *ef.dbName,
 	}
+}
+
+// volumes returns a VolumeManager for the code and spdx volumes.
+func (ef *envFlags) volumes() *fixtures.VolumeManager {
+	return fixtures.NewVolumeManager(
+		&fixtures.Volume{Name: "code", Root: *ef.codeDir, Seed: filepath.Join(*ef.seedDir, "code")},
+		&fixtures.Volume{Name: "spdx", Root: *ef.spdxDir, Seed: filepath.Join(*ef.seedDir, "spdx")},
+	)
+}
 
-	fmt.Printf("\n\n")
+// testFlags controls which generated tests are registered.
+type testFlags struct {
+	propertyRuns   *int
+	propertySteps  *int
+	propertySeed   *int64
+	fuzzIterations *int
+	fuzzSeed       *int64
+	fuzzTimeout    *time.Duration
+	fuzzDir        *string
+	tags           *string
+	skipTags       *string
+}
 
-	// set up tabwriter for outputting test result table
-	w := tabwriter.NewWriter(os.Stdout, 8, 4, 1, ' ', 0)
+// addTestFlags adds the flags for the generated tests to fs.
+func addTestFlags(fs *flag.FlagSet) *testFlags {
+	return &testFlags{
+		propertyRuns:   fs.Int("property-runs", 0, "number of random job operation sequences to run as property tests"),
+		propertySteps:  fs.Int("property-steps", 20, "number of operations in each property test sequence"),
+		propertySeed:   fs.Int64("property-seed", 1, "random seed for the first property test sequence"),
+		fuzzIterations: fs.Int("fuzz-iterations", 0, "number of mutated request bodies to send to each POST/PUT endpoint"),
+		fuzzSeed:       fs.Int64("fuzz-seed", 1, "random seed for fuzzing request bodies"),
+		fuzzTimeout:    fs.Duration("fuzz-timeout", 10*time.Second, "how long to wait for a response to a fuzzed request before treating it as hung"),
+		fuzzDir:        fs.String("fuzz-dir", "fuzz-failures", "directory where failing fuzz inputs are saved"),
+		tags:           fs.String("tags", "", `tag expression selecting tests, e.g. "readonly" or "jobs+!slow,agent:nop"; "," means or, "+" means and, "!" means not`),
+		skipTags:       fs.String("skip-tags", "", "tag expression selecting tests to skip, even if -tags selects them"),
+	}
+}
 
-	// output results
-	for _, r := range allRs {
-		var result string
-		if r.Success {
-			result = "ok"
-		} else {
-			result = "FAIL"
-			anyFailed = true
-		}
+// suites returns all registered suites. reset is used by
+// property tests to reset the DB while shrinking; it may be nil.
+func (tf *testFlags) suites(reset func() error) []testresult.Suite {
+	return []testresult.Suite{
+		{Name: "volumes", Tests: volumes.GetTests()},
+		{Name: "agents", Tests: agents.GetTests(), Hooks: agents.GetHooks()},
+		{Name: "jobconfig", Tests: jobconfig.GetTests()},
+		{Name: "scheduling", Tests: scheduling.GetTests(), Hooks: scheduling.GetHooks()},
+		{Name: "concurrency", Tests: concurrency.GetTests()},
+		{Name: "property", Tests: property.GetTests(property.Config{
+			Runs:  *tf.propertyRuns,
+			Steps: *tf.propertySteps,
+			Seed:  *tf.propertySeed,
+			Reset: reset,
+		})},
+		{Name: "fuzz", Tests: fuzz.GetTests(fuzz.Config{
+			Iterations: *tf.fuzzIterations,
+			Seed:       *tf.fuzzSeed,
+			Timeout:    *tf.fuzzTimeout,
+			Dir:        *tf.fuzzDir,
+		})},
+	}
+}
 
-		fmt.Fprintf(w, "%s\t%s\t%s\t%s\n", r.Suite, r.Element, r.ID, result)
+// selectTests returns the suites with only the tests whose tags
+// match the -tags and -skip-tags expressions, and whose
+// Suite:Element:ID names match pattern. Any of these that is
+// empty selects every test. The names are found with a dry run
+// of each test. Suites with no selected tests are left out.
+func (tf *testFlags) selectTests(suites []testresult.Suite, root string, pattern string) ([]testresult.Suite, error) {
+	include, err := testresult.ParseTagExpr(*tf.tags)
+	if err != nil {
+		return nil, err
 	}
-	w.Flush()
-
-	if anyFailed {
-		// print details of failing tests
-		fmt.Printf("\n\n==========\n\n")
-		for _, r := range allRs {
-			if !r.Success {
-				fmt.Printf("%s:%s:%s\n", r.Suite, r.Element, r.ID)
-				fmt.Printf("    Status: FAIL\n")
-				fmt.Printf("    Step:   %s\n", r.FailStep)
-				fmt.Printf("    Errors: %v\n", r.FailError)
-				fmt.Printf("    Wanted: %s\n", r.Wanted)
-				fmt.Printf("    Got:    %s\n", r.Got)
-				fmt.Printf("\n==========\n\n")
+	exclude, err := testresult.ParseTagExpr(*tf.skipTags)
+	if err != nil {
+		return nil, err
+	}
+	var re *regexp.Regexp
+	if pattern != "" {
+		re, err = regexp.Compile(pattern)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	selected := []testresult.Suite{}
+	for _, s := range suites {
+		tests := []testresult.Test{}
+		for _, t := range s.Tests {
+			if include != nil && !include.Match(t) {
+				continue
+			}
+			if exclude != nil && exclude.Match(t) {
+				continue
 			}
+			if re != nil {
+				res, _ := utils.DryRunTest(t.Func, root)
+				if !re.MatchString(res.Name()) {
+					continue
+				}
+			}
+			tests = append(tests, t)
+		}
+		if len(tests) > 0 {
+			s.Tests = tests
+			selected = append(selected, s)
 		}
+	}
+	return selected, nil
+}
 
-		// return failure status code
-		os.Exit(1)
+// countTests returns the number of tests in suites.
+func countTests(suites []testresult.Suite) int {
+	n := 0
+	for _, s := range suites {
+		n += len(s.Tests)
 	}
+	return n
 }
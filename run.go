@@ -0,0 +1,362 @@
+// SPDX-License-Identifier: Apache-2.0 OR GPL-2.0-or-later
+
+package main
+
+import (
+	"bufio"
+	"flag"
+	"fmt"
+	"net/http"
+	"os"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/swinslow/peridot-jobrunner-testing/fixtures"
+	"github.com/swinslow/peridot-jobrunner-testing/internal/baseline"
+	"github.com/swinslow/peridot-jobrunner-testing/internal/har"
+	"github.com/swinslow/peridot-jobrunner-testing/internal/history"
+	"github.com/swinslow/peridot-jobrunner-testing/internal/preflight"
+	"github.com/swinslow/peridot-jobrunner-testing/internal/ready"
+	"github.com/swinslow/peridot-jobrunner-testing/internal/report"
+	"github.com/swinslow/peridot-jobrunner-testing/internal/runner"
+	"github.com/swinslow/peridot-jobrunner-testing/internal/testresult"
+	"github.com/swinslow/peridot-jobrunner-testing/test/utils"
+)
+
+// preflightHAR labels the recorded traffic of the preflight
+// environment collection.
+const preflightHAR = "preflight"
+
+// runTests implements the run subcommand, which runs the tests,
+// resetting the fixtures before each one, and reports on their
+// results. It returns the process exit code.
+func runTests(args []string) int {
+	fs := flag.NewFlagSet("run", flag.ExitOnError)
+	ef := addEnvFlags(fs)
+	tf := addTestFlags(fs)
+	snapshot := fs.Bool("snapshot", true, "restore the DB fixture from a postgres snapshot instead of rebuilding it via the API")
+	rerunFailed := fs.Int("rerun-failed", 0, "number of times to rerun a failing test, to detect flaky tests")
+	historyPath := fs.String("history", "", "JSON file in which to track pass rates across runs; empty to disable")
+	baselinePath := fs.String("baseline", "", "JSON file of a previous run's results to compare this run against; empty to disable")
+	saveBaseline := fs.String("save-baseline", "", "JSON file in which to save this run's results, for later use with -baseline; empty to disable")
+	update := fs.Bool("update", false, "write each response checked against a golden file to that file, instead of comparing")
+	goldenDir := fs.String("golden-dir", "testdata/golden", "directory containing the golden files of expected responses")
+	htmlPath := fs.String("html", "", "file in which to write an HTML report of the results; empty to disable")
+	harPath := fs.String("har", "", "HAR file in which to record all HTTP traffic for the run; empty to disable; implies -snapshot=false")
+	harDir := fs.String("har-dir", "", "directory in which to record each test's HTTP traffic as a separate HAR file; empty to disable; implies -snapshot=false")
+	harRedact := fs.Bool("har-redact", true, "redact Authorization headers in HAR files; a redacted recording replays without checking which user made each request")
+	pauseOnFail := fs.Bool("pause-on-fail", false, "after a test fails, leave the DB and volumes as they are and wait for input before continuing; needs a terminal, e.g. with docker-compose run")
+	runPattern := fs.String("run", "", "regular expression selecting which tests to run, matched against each test's Suite:Element:ID name")
+	replayPath := fs.String("replay", "", "HAR file of a recorded run to serve responses from, instead of calling the API; implies -snapshot=false; the volumes are not reset, and steps that check files in them are skipped")
+	wait := fs.Bool("wait", true, "wait for postgres, the API, the jobrunner and the agents to come up before testing")
+	waitTimeout := fs.Duration("wait-timeout", 60*time.Second, "how long to wait for each dependency to come up")
+	waitBackoff := fs.Duration("wait-backoff", 250*time.Millisecond, "initial delay between readiness probes, doubling after each failure")
+	waitMaxBackoff := fs.Duration("wait-max-backoff", 5*time.Second, "maximum delay between readiness probes")
+	jobrunnerAddr := fs.String("jobrunner-addr", "sut:3001", "host:port of the jobrunner's gRPC server, for readiness probes; empty to skip")
+	fs.Parse(args)
+
+	// pausing waits for input, which docker-compose up does not
+	// give, so refuse rather than stop at the first failure
+	if *pauseOnFail && !isTerminal(os.Stdin) {
+		fmt.Printf("Error: -pause-on-fail needs stdin to be a terminal; under docker-compose, use e.g.\n")
+		fmt.Printf("    docker-compose run --rm test /peridot-jobrunner-testing/peridot-jobrunner-testing run -pause-on-fail\n")
+		return 2
+	}
+
+	anyFailed := false
+
+	// when replaying, every response comes from the recording,
+	// so the DB fixture must be reset via the API as well
+	var player *har.Player
+	if *replayPath != "" {
+		entries, err := har.Load(*replayPath)
+		if err != nil {
+			fmt.Printf("Error loading replay file %s: %v\n", *replayPath, err)
+			return 1
+		}
+		// a replay does not collect the environment again, so
+		// its recorded traffic is left out
+		replayed := []*har.Entry{}
+		for _, e := range entries {
+			if e.Comment != preflightHAR {
+				replayed = append(replayed, e)
+			}
+		}
+		player = har.NewPlayer(replayed)
+		utils.Transport = player
+		*snapshot = false
+	}
+
+	// a recording must hold every DB reset for it to be replayed,
+	// and restoring a snapshot bypasses the API
+	if (*harPath != "" || *harDir != "") && *snapshot {
+		fmt.Printf("Recording HTTP traffic, so the DB fixture is rebuilt via the API instead of restored from snapshots\n")
+		*snapshot = false
+	}
+
+	pgConfig := ef.pgConfig()
+
+	// a replay has no dependencies to wait for
+	if *wait && player == nil {
+		b := ready.Backoff{Initial: *waitBackoff, Max: *waitMaxBackoff}
+		if !waitForDependencies(*ef.apiRoot, pgConfig, *jobrunnerAddr, *waitTimeout, b) {
+			return 1
+		}
+	}
+
+	var pg *fixtures.PGConfig
+	if *snapshot {
+		pg = pgConfig
+	}
+	dbFixture := fixtures.NewDBFixture(*ef.apiRoot, pg)
+	utils.CodeDir = *ef.codeDir
+	utils.SpdxDir = *ef.spdxDir
+	utils.SeedDir = *ef.seedDir
+	utils.GoldenDir = *goldenDir
+	utils.UpdateGolden = *update
+	rn := &runner.Runner{
+		Root:   *ef.apiRoot,
+		DB:     dbFixture,
+		Reruns: *rerunFailed,
+		HARDir: *harDir,
+		Out:    os.Stdout,
+	}
+	// a replay has no volumes, so it leaves them alone and skips
+	// the steps that check files in them
+	if player == nil {
+		rn.Volumes = ef.volumes()
+	} else {
+		utils.Replay = true
+	}
+	if *harPath != "" || *harDir != "" {
+		rn.HAR = har.NewRecorder(utils.Transport, *harRedact)
+		utils.Transport = rn.HAR
+	}
+
+	// a replay has no services to describe
+	var env *preflight.Environment
+	if player == nil {
+		env = preflight.Collect(preflight.Config{
+			APIRoot:        *ef.apiRoot,
+			JobrunnerAddr:  *jobrunnerAddr,
+			Client:         utils.Client(),
+			Authorize:      func(req *http.Request) { utils.AddAuthHeader(nil, "", req, "viewer") },
+			FallbackAgents: fixtures.AgentAddrs(),
+			Versions:       preflight.EnvVersions(os.Environ()),
+		})
+		rn.TakeHAR(preflightHAR)
+		fmt.Printf("Environment:\n")
+		env.Print(os.Stdout)
+		fmt.Printf("\n")
+	}
+
+	suites, err := tf.selectTests(tf.suites(dbFixture.Reset), *ef.apiRoot, *runPattern)
+	if err != nil {
+		fmt.Printf("Error selecting tests: %v\n", err)
+		return 2
+	}
+
+	// a replay has no DB or volumes to inspect
+	if *pauseOnFail && player == nil {
+		stdin := bufio.NewReader(os.Stdin)
+		rn.Pause = func(res *testresult.TestResult) bool {
+			return pauseAfterFailure(res, *ef.apiRoot, *ef.codeDir, *ef.spdxDir, stdin)
+		}
+	}
+
+	// and run them, resetting DB and volume each time
+	fmt.Printf("Testing (%d total): \n", countTests(suites))
+	allRs := rn.Run(suites)
+
+	dbFixture.Close()
+
+	if *harPath != "" {
+		err := har.Save(*harPath, rn.HAREntries)
+		if err != nil {
+			fmt.Printf("Error saving HAR file to %s: %v\n", *harPath, err)
+		}
+	}
+
+	fmt.Printf("\n\n")
+
+	// set up tabwriter for outputting test result table
+	w := tabwriter.NewWriter(os.Stdout, 8, 4, 1, ' ', 0)
+
+	// output results
+	for _, r := range allRs {
+		var result string
+		switch {
+		case r.Success:
+			result = "ok"
+		case r.Flaky:
+			result = fmt.Sprintf("FLAKY (passed on attempt %d)", r.Attempts)
+			anyFailed = true
+		default:
+			result = "FAIL"
+			if r.Attempts > 1 {
+				result = fmt.Sprintf("FAIL (all %d attempts)", r.Attempts)
+			}
+			anyFailed = true
+		}
+
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\n", r.Suite, r.Element, r.ID, result)
+	}
+	for _, h := range rn.HookResults {
+		result := "HOOK FAIL"
+		if h.Skipped > 0 {
+			result = fmt.Sprintf("HOOK FAIL (skipped %d)", h.Skipped)
+		}
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\n", h.Suite, h.Hook, h.Test, result)
+		anyFailed = true
+	}
+	w.Flush()
+
+	if err := dbFixture.FallbackReason(); err != nil {
+		fmt.Printf("\nWARNING: DB snapshots were enabled but fell back to API resets: %v\n", err)
+	}
+
+	if *historyPath != "" {
+		printHistory(*historyPath, allRs)
+	}
+
+	if player != nil {
+		printReplay(*replayPath, player)
+	}
+
+	if *htmlPath != "" {
+		err := report.WriteFile(*htmlPath, allRs, rn.HookResults, env, time.Now())
+		if err != nil {
+			fmt.Printf("\nError writing HTML report to %s: %v\n", *htmlPath, err)
+		}
+	}
+
+	if *baselinePath != "" || *saveBaseline != "" {
+		printBaseline(*baselinePath, *saveBaseline, allRs, rn.HookResults, env)
+	}
+
+	if anyFailed {
+		// print details of failing tests and hooks
+		fmt.Printf("\n\n==========\n\n")
+		for _, r := range allRs {
+			if !r.Success {
+				status := "FAIL"
+				if r.Flaky {
+					status = "FLAKY"
+				}
+				fmt.Printf("%s\n", r.Name())
+				fmt.Printf("    Status: %s\n", status)
+				fmt.Printf("    Step:   %s\n", r.FailStep)
+				fmt.Printf("    Errors: %v\n", r.FailError)
+				fmt.Printf("    Wanted: %s\n", r.Wanted)
+				fmt.Printf("    Got:    %s\n", r.Got)
+				if diff := utils.MatchDiff(r); diff != "" {
+					fmt.Printf("    Diff:\n")
+					for _, line := range strings.Split(strings.TrimRight(diff, "\n"), "\n") {
+						fmt.Printf("      %s\n", line)
+					}
+				}
+				if len(r.Transitions) > 0 {
+					fmt.Printf("    Jobs:\n")
+					for _, tr := range r.Transitions {
+						fmt.Printf("      %s  job %d: %s / %s\n", tr.At.Format("15:04:05.000"), tr.JobID, tr.Status, tr.Health)
+					}
+				}
+				if len(r.Diagnostics) > 0 {
+					fmt.Printf("    Diagnostics:\n")
+					for _, d := range r.Diagnostics {
+						fmt.Printf("      %s\n", d)
+					}
+				}
+				fmt.Printf("\n==========\n\n")
+			}
+		}
+		for _, h := range rn.HookResults {
+			fmt.Printf("%s %s\n", h.Suite, h.Hook)
+			fmt.Printf("    Status:  HOOK FAIL\n")
+			if h.Test != "" {
+				fmt.Printf("    Test:    %s\n", h.Test)
+			}
+			fmt.Printf("    Errors:  %v\n", h.Err)
+			fmt.Printf("    Skipped: %d\n", h.Skipped)
+			fmt.Printf("\n==========\n\n")
+		}
+
+		// return failure status code
+		return 1
+	}
+
+	return 0
+}
+
+// printHistory adds this run's results to the history file at
+// path, and prints any tests whose recent history is unstable.
+func printHistory(path string, allRs []*testresult.TestResult) {
+	h, err := history.Load(path)
+	if err != nil {
+		fmt.Printf("\nError loading history from %s: %v\n", path, err)
+		return
+	}
+	h.Add(allRs, time.Now())
+	err = h.Save(path)
+	if err != nil {
+		fmt.Printf("\nError saving history to %s: %v\n", path, err)
+	}
+
+	unstable := h.Unstable()
+	if len(unstable) == 0 {
+		return
+	}
+	fmt.Printf("\nTests with unstable history:\n")
+	w := tabwriter.NewWriter(os.Stdout, 8, 4, 1, ' ', 0)
+	for _, name := range unstable {
+		rec := h.Tests[name]
+		fmt.Fprintf(w, "  %s\t%.0f%% passed\t(%d runs, %d flaky)\n", name, 100*rec.PassRate(), rec.Runs, rec.Flaky)
+	}
+	w.Flush()
+}
+
+// printReplay prints any requests that had no recorded response
+// in the replay file at path, and how many recorded responses
+// were never used.
+func printReplay(path string, player *har.Player) {
+	misses := player.Misses()
+	unused := player.Unused()
+	if len(misses) == 0 && unused == 0 {
+		return
+	}
+	fmt.Printf("\nReplay from %s did not match the recording:\n", path)
+	if len(misses) > 0 {
+		fmt.Printf("  %d requests had no recorded response:\n", len(misses))
+		for _, m := range misses {
+			fmt.Printf("    %s\n", m)
+		}
+	}
+	if unused > 0 {
+		fmt.Printf("  %d recorded responses were not used\n", unused)
+	}
+}
+
+// printBaseline compares this run's results against the baseline
+// file at oldPath, if any, and prints what changed. It then saves
+// this run's results to savePath, if any.
+func printBaseline(oldPath string, savePath string, allRs []*testresult.TestResult, hooks []*testresult.HookResult, env *preflight.Environment) {
+	cur := baseline.FromResults(allRs, hooks, env, time.Now())
+
+	if oldPath != "" {
+		old, err := baseline.Load(oldPath)
+		if err != nil {
+			fmt.Printf("\nError loading baseline from %s: %v\n", oldPath, err)
+		} else {
+			fmt.Printf("\nCompared to baseline from %s:\n", old.Created.Format(time.RFC3339))
+			baseline.Compare(old, cur).Print(os.Stdout)
+		}
+	}
+
+	if savePath != "" {
+		err := cur.Save(savePath)
+		if err != nil {
+			fmt.Printf("\nError saving baseline to %s: %v\n", savePath, err)
+		}
+	}
+}
@@ -4,13 +4,28 @@ package agents
 
 import (
 	"github.com/swinslow/peridot-jobrunner-testing/internal/testresult"
+	"github.com/swinslow/peridot-jobrunner-testing/test/utils"
 )
 
 // GetTests returns all of the endpoints test suites.
-func GetTests() []testresult.TestFunc {
-	allTests := []testresult.TestFunc{}
+func GetTests() []testresult.Test {
+	allTests := []testresult.Test{}
 
 	allTests = append(allTests, getNopTests()...)
 
 	return allTests
 }
+
+// GetHooks returns the hooks for the agents tests. After a test
+// fails, they record the jobs in the fixture repo pulls that the
+// tests use, as the jobrunner left them.
+func GetHooks() testresult.Hooks {
+	return testresult.Hooks{
+		AfterEach: func(root string, res *testresult.TestResult) error {
+			if res.Success {
+				return nil
+			}
+			return utils.DumpJobs(res, root, 3, 4)
+		},
+	}
+}
@@ -3,19 +3,24 @@
 package agents
 
 import (
+	"fmt"
+	"path/filepath"
+	"time"
+
 	"github.com/swinslow/peridot-jobrunner-testing/internal/testresult"
 	"github.com/swinslow/peridot-jobrunner-testing/test/utils"
 )
 
-func getNopTests() []testresult.TestFunc {
-	return []testresult.TestFunc{
-		jobsSubGetOperator,
-		jobsSubPostOperator,
-		jobsGetOneViewer,
-		jobsPutOneOperator,
-		jobsPutOneViewer,
-		jobsDeleteOneAdmin,
-		jobsDeleteOneOperator,
+func getNopTests() []testresult.Test {
+	return []testresult.Test{
+		{Func: jobsSubGetOperator, Tags: []string{"readonly", "jobs"}},
+		{Func: jobsSubPostOperator, Tags: []string{"destructive", "jobs"}},
+		{Func: jobsGetOneViewer, Tags: []string{"readonly", "jobs"}},
+		{Func: jobsPutOneOperator, Tags: []string{"destructive", "jobs"}},
+		{Func: jobsPutOneViewer, Tags: []string{"auth", "jobs"}},
+		{Func: jobsDeleteOneAdmin, Tags: []string{"destructive", "jobs"}},
+		{Func: jobsDeleteOneOperator, Tags: []string{"auth", "jobs"}},
+		{Func: nopRunOperator, Tags: []string{"destructive", "jobs", "slow", "agent:nop"}},
 	}
 }
 
@@ -30,18 +35,13 @@ func jobsSubGetOperator(root string) *testresult.TestResult {
 
 	url := root + "/repopulls/4/jobs"
 
-	res.Wanted = `{"jobs":[
-		{"id":2, "repopull_id":4, "agent_id":1, "started_at":"0001-01-01T00:00:00Z", "finished_at":"0001-01-01T00:00:00Z", "status":"startup", "health":"ok", "is_ready":true, "config":{}},
-		{"id":3, "repopull_id":4, "agent_id":2, "priorjob_ids": [2], "started_at":"0001-01-01T00:00:00Z", "finished_at":"0001-01-01T00:00:00Z", "status":"startup", "health":"ok", "is_ready":true, "config":{"codereader": {"primary": {"path": "/somewhere"}}}},
-		{"id":4, "repopull_id":4, "agent_id":4, "priorjob_ids": [2,3], "started_at":"0001-01-01T00:00:00Z", "finished_at":"0001-01-01T00:00:00Z", "status":"startup", "health":"ok", "is_ready":false, "config":{"kv": {"hello":"world"}, "codereader": {"godeps": {"priorjob_id": 3}}, "spdxreader": {"primary": {"path": "/path/wherever"}, "godeps": {"priorjob_id": 3}}}}
-	]}`
 	err := utils.GetContent(res, "1", url, 200, "viewer")
 	if err != nil {
 		return res
 	}
 
-	if !utils.IsMatch(res) {
-		utils.FailMatch(res, "2")
+	err = utils.CheckGolden(res, "2")
+	if err != nil {
 		return res
 	}
 
@@ -64,30 +64,26 @@ func jobsSubPostOperator(root string) *testresult.TestResult {
 	body := `{"agent_id":1, "is_ready":false, "priorjob_ids":[],
 		"config":{"kv": {"hi": "there", "hello": "world"}}
 	}`
-	res.Wanted = `{"id": 5}`
 	err := utils.Post(res, "1", url, body, 201, "operator")
 	if err != nil {
 		return res
 	}
 
-	if !utils.IsMatch(res) {
-		utils.FailMatch(res, "2")
+	err = utils.CheckGolden(res, "2")
+	if err != nil {
 		return res
 	}
 
 	// now, confirm that a new job was actually added
 	// this should be the only one for repopull 3 so we can reuse the same url
 	// priorjob_ids and some config vals should be absent
-	res.Wanted = `{"jobs":[
-		{"id":5, "repopull_id":3, "agent_id":1, "started_at":"0001-01-01T00:00:00Z", "finished_at":"0001-01-01T00:00:00Z", "status":"startup", "health":"ok", "is_ready":false, "config":{"kv": {"hi": "there", "hello": "world"}}}
-	]}`
 	err = utils.GetContent(res, "3", url, 200, "operator")
 	if err != nil {
 		return res
 	}
 
-	if !utils.IsMatch(res) {
-		utils.FailMatch(res, "4")
+	err = utils.CheckGolden(res, "4")
+	if err != nil {
 		return res
 	}
 
@@ -106,14 +102,13 @@ func jobsGetOneViewer(root string) *testresult.TestResult {
 
 	url := root + "/jobs/4"
 
-	res.Wanted = `{"job":{"id":4, "repopull_id":4, "agent_id":4, "priorjob_ids": [2,3], "started_at":"0001-01-01T00:00:00Z", "finished_at":"0001-01-01T00:00:00Z", "status":"startup", "health":"ok", "is_ready":false, "config":{"kv": {"hello":"world"}, "codereader": {"godeps": {"priorjob_id": 3}}, "spdxreader": {"primary": {"path": "/path/wherever"}, "godeps": {"priorjob_id": 3}}}}}`
 	err := utils.GetContent(res, "1", url, 200, "viewer")
 	if err != nil {
 		return res
 	}
 
-	if !utils.IsMatch(res) {
-		utils.FailMatch(res, "2")
+	err = utils.CheckGolden(res, "2")
+	if err != nil {
 		return res
 	}
 
@@ -148,14 +143,13 @@ func jobsPutOneOperator(root string) *testresult.TestResult {
 
 	// now, confirm that the job was actually updated
 	// is_ready should now be true
-	res.Wanted = `{"job":{"id":4, "repopull_id":4, "agent_id":4, "priorjob_ids": [2,3], "started_at":"0001-01-01T00:00:00Z", "finished_at":"0001-01-01T00:00:00Z", "status":"startup", "health":"ok", "is_ready":true, "config":{"kv": {"hello":"world"}, "codereader": {"godeps": {"priorjob_id": 3}}, "spdxreader": {"primary": {"path": "/path/wherever"}, "godeps": {"priorjob_id": 3}}}}}`
 	err = utils.GetContent(res, "3", url, 200, "operator")
 	if err != nil {
 		return res
 	}
 
-	if !utils.IsMatch(res) {
-		utils.FailMatch(res, "4")
+	err = utils.CheckGolden(res, "4")
+	if err != nil {
 		return res
 	}
 
@@ -173,27 +167,25 @@ func jobsPutOneViewer(root string) *testresult.TestResult {
 	url := root + "/jobs/4"
 
 	body := `{"is_ready": true}`
-	res.Wanted = `{"error": "Access denied"}`
 	err := utils.Put(res, "1", url, body, 403, "viewer")
 	if err != nil {
 		return res
 	}
 
-	if !utils.IsMatch(res) {
-		utils.FailMatch(res, "2")
+	err = utils.CheckGolden(res, "2")
+	if err != nil {
 		return res
 	}
 
 	// now, confirm that the job was NOT actually updated
 	// is_ready should still be false
-	res.Wanted = `{"job":{"id":4, "repopull_id":4, "agent_id":4, "priorjob_ids": [2,3], "started_at":"0001-01-01T00:00:00Z", "finished_at":"0001-01-01T00:00:00Z", "status":"startup", "health":"ok", "is_ready":false, "config":{"kv": {"hello":"world"}, "codereader": {"godeps": {"priorjob_id": 3}}, "spdxreader": {"primary": {"path": "/path/wherever"}, "godeps": {"priorjob_id": 3}}}}}`
 	err = utils.GetContent(res, "3", url, 200, "operator")
 	if err != nil {
 		return res
 	}
 
-	if !utils.IsMatch(res) {
-		utils.FailMatch(res, "4")
+	err = utils.CheckGolden(res, "4")
+	if err != nil {
 		return res
 	}
 
@@ -228,17 +220,13 @@ func jobsDeleteOneAdmin(root string) *testresult.TestResult {
 	// NOTE that job ID 3 is also removed from priorjob_ids and config for job 4.
 	// FIXME the deleted job should not cascade in this way.
 	allURL := root + "/repopulls/4/jobs"
-	res.Wanted = `{"jobs":[
-		{"id":2, "repopull_id":4, "agent_id":1, "started_at":"0001-01-01T00:00:00Z", "finished_at":"0001-01-01T00:00:00Z", "status":"startup", "health":"ok", "is_ready":true, "config":{}},
-		{"id":4, "repopull_id":4, "agent_id":4, "priorjob_ids": [2], "started_at":"0001-01-01T00:00:00Z", "finished_at":"0001-01-01T00:00:00Z", "status":"startup", "health":"ok", "is_ready":false, "config":{"kv": {"hello":"world"}, "spdxreader": {"primary": {"path": "/path/wherever"}}}}
-	]}`
 	err = utils.GetContent(res, "3", allURL, 200, "viewer")
 	if err != nil {
 		return res
 	}
 
-	if !utils.IsMatch(res) {
-		utils.FailMatch(res, "4")
+	err = utils.CheckGolden(res, "4")
+	if err != nil {
 		return res
 	}
 
@@ -256,31 +244,78 @@ func jobsDeleteOneOperator(root string) *testresult.TestResult {
 	url := root + "/jobs/3"
 
 	// try and fail to delete the job
-	res.Wanted = `{"error": "Access denied"}`
 	err := utils.Delete(res, "1", url, ``, 403, "operator")
 	if err != nil {
 		return res
 	}
 
-	if !utils.IsMatch(res) {
-		utils.FailMatch(res, "2")
+	err = utils.CheckGolden(res, "2")
+	if err != nil {
 		return res
 	}
 
 	// now, confirm that the job has NOT been deleted
 	allURL := root + "/repopulls/4/jobs"
-	res.Wanted = `{"jobs":[
-		{"id":2, "repopull_id":4, "agent_id":1, "started_at":"0001-01-01T00:00:00Z", "finished_at":"0001-01-01T00:00:00Z", "status":"startup", "health":"ok", "is_ready":true, "config":{}},
-		{"id":3, "repopull_id":4, "agent_id":2, "priorjob_ids": [2], "started_at":"0001-01-01T00:00:00Z", "finished_at":"0001-01-01T00:00:00Z", "status":"startup", "health":"ok", "is_ready":true, "config":{"codereader": {"primary": {"path": "/somewhere"}}}},
-		{"id":4, "repopull_id":4, "agent_id":4, "priorjob_ids": [2,3], "started_at":"0001-01-01T00:00:00Z", "finished_at":"0001-01-01T00:00:00Z", "status":"startup", "health":"ok", "is_ready":false, "config":{"kv": {"hello":"world"}, "codereader": {"godeps": {"priorjob_id": 3}}, "spdxreader": {"primary": {"path": "/path/wherever"}, "godeps": {"priorjob_id": 3}}}}
-	]}`
 	err = utils.GetContent(res, "3", allURL, 200, "viewer")
 	if err != nil {
 		return res
 	}
 
-	if !utils.IsMatch(res) {
-		utils.FailMatch(res, "4")
+	err = utils.CheckGolden(res, "4")
+	if err != nil {
+		return res
+	}
+
+	utils.Pass(res)
+	return res
+}
+
+// ===== running a nop job to completion
+
+func nopRunOperator(root string) *testresult.TestResult {
+	res := &testresult.TestResult{
+		Suite:   "jobrunner",
+		Element: "nop",
+		ID:      "run (operator)",
+	}
+
+	url := root + "/repopulls/3/jobs"
+
+	// first, send POST to add a new job that is ready to run
+	body := `{"agent_id":1, "is_ready":true, "priorjob_ids":[], "config":{}}`
+	err := utils.Post(res, "1", url, body, 201, "operator")
+	if err != nil {
+		return res
+	}
+
+	err = utils.CheckGolden(res, "2")
+	if err != nil {
+		return res
+	}
+	id, err := utils.ParseID(res, "3")
+	if err != nil {
+		return res
+	}
+
+	// now, wait for the jobrunner to run it to completion
+	job, err := utils.WaitForJob(res, "4", root, id, "stopped", "ok", 30*time.Second, "operator")
+	if err != nil {
+		return res
+	}
+
+	if job.StartedAt.IsZero() || job.FinishedAt.Before(job.StartedAt) {
+		utils.FailTest(res, "5", fmt.Errorf("expected started_at before finished_at, got %v and %v", job.StartedAt, job.FinishedAt))
+		return res
+	}
+
+	// finally, confirm that nop, which writes no output, left
+	// the volumes untouched
+	err = utils.CheckTree(res, "6", utils.CodeDir, filepath.Join(utils.SeedDir, "code"))
+	if err != nil {
+		return res
+	}
+	err = utils.CheckTree(res, "7", utils.SpdxDir, filepath.Join(utils.SeedDir, "spdx"))
+	if err != nil {
 		return res
 	}
 
@@ -0,0 +1,146 @@
+// SPDX-License-Identifier: Apache-2.0 OR GPL-2.0-or-later
+
+// Package concurrency fires simultaneous job mutations at the
+// API from multiple roles, and then checks invariants on the
+// resulting state that any serial order of the same requests
+// would preserve.
+package concurrency
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"sync"
+
+	"github.com/swinslow/peridot-jobrunner-testing/internal/testresult"
+	"github.com/swinslow/peridot-jobrunner-testing/test/utils"
+)
+
+// repoPullID is the fixture repo pull that scenarios create
+// their jobs in. It has no jobs in the fixture state.
+const repoPullID = 3
+
+// fixtureRepoPullID is the fixture repo pull that already has
+// jobs 2, 3 and 4 in the fixture state.
+const fixtureRepoPullID = 4
+
+// GetTests returns all of the concurrency test suites.
+func GetTests() []testresult.Test {
+	tags := []string{"destructive", "jobs", "concurrency"}
+	return []testresult.Test{
+		{Func: concurrentPost, Tags: tags},
+		{Func: concurrentPut, Tags: append(tags, "auth")},
+		{Func: concurrentDeleteSame, Tags: tags},
+		{Func: concurrentDeleteAndPost, Tags: tags},
+	}
+}
+
+// response is the outcome of one concurrent request.
+type response struct {
+	code int
+	body []byte
+	err  error
+}
+
+// request makes one HTTP call and returns its outcome. Unlike
+// the utils helpers it does not touch a TestResult, so that it
+// can be called from many goroutines at once.
+func request(method string, url string, body string, ghUsername string) response {
+	req, err := http.NewRequest(method, url, strings.NewReader(body))
+	if err != nil {
+		return response{err: err}
+	}
+	utils.AddAuthHeader(nil, "", req, ghUsername)
+
+	resp, err := utils.Client().Do(req)
+	if err != nil {
+		return response{err: err}
+	}
+	defer resp.Body.Close()
+	b, err := ioutil.ReadAll(resp.Body)
+	return response{code: resp.StatusCode, body: b, err: err}
+}
+
+// fire calls each of fns in its own goroutine, releasing them
+// all at once so that their requests overlap as much as
+// possible, and returns their responses in the same order.
+func fire(fns []func() response) []response {
+	out := make([]response, len(fns))
+	start := make(chan struct{})
+	var wg sync.WaitGroup
+	for i := range fns {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			<-start
+			out[i] = fns[i]()
+		}(i)
+	}
+	close(start)
+	wg.Wait()
+	return out
+}
+
+// createdID parses the ID from a successful POST response.
+func createdID(r response) (uint32, error) {
+	var js struct {
+		ID uint32 `json:"id"`
+	}
+	err := json.Unmarshal(r.body, &js)
+	if err != nil {
+		return 0, err
+	}
+	return js.ID, nil
+}
+
+// checkNoOrphans confirms that every priorjob_id of every job
+// in jobs refers to a job that exists. On failure, it fills in
+// the failure code in the TestResult and returns an error.
+func checkNoOrphans(res *testresult.TestResult, step string, root string, jobs []*utils.Job) error {
+	exists := map[uint32]bool{}
+	for _, j := range jobs {
+		exists[j.ID] = true
+	}
+
+	for _, j := range jobs {
+		for _, pid := range j.PriorJobIDs {
+			if exists[pid] {
+				continue
+			}
+			// the prior job may be in another repo pull
+			url := fmt.Sprintf("%s/jobs/%d", root, pid)
+			r := request("GET", url, "", "viewer")
+			if r.err == nil && r.code == 200 {
+				exists[pid] = true
+				continue
+			}
+			err := fmt.Errorf("job %d has orphaned prior job %d", j.ID, pid)
+			utils.FailTest(res, step, err)
+			return err
+		}
+	}
+
+	return nil
+}
+
+// checkResponses confirms that every response came back with
+// one of the allowed status codes for its index. On failure, it
+// fills in the failure code in the TestResult and returns an
+// error.
+func checkResponses(res *testresult.TestResult, step string, rs []response, allowed func(i int, code int) bool) error {
+	for i, r := range rs {
+		if r.err != nil {
+			utils.FailTest(res, step, fmt.Errorf("request %d failed: %v", i+1, r.err))
+			return r.err
+		}
+		if !allowed(i, r.code) {
+			res.Got = r.body
+			err := fmt.Errorf("request %d got unexpected HTTP status code %d", i+1, r.code)
+			utils.FailTest(res, step, err)
+			return err
+		}
+	}
+	return nil
+}
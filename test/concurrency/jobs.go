@@ -0,0 +1,277 @@
+// SPDX-License-Identifier: Apache-2.0 OR GPL-2.0-or-later
+
+package concurrency
+
+import (
+	"fmt"
+
+	"github.com/swinslow/peridot-jobrunner-testing/internal/testresult"
+	"github.com/swinslow/peridot-jobrunner-testing/test/utils"
+)
+
+// numRequests is how many simultaneous requests each scenario
+// fires.
+const numRequests = 20
+
+// roles alternate between the users that may mutate jobs.
+var roles = []string{"operator", "admin"}
+
+// ===== simultaneous POST /repopulls/id/jobs
+
+func concurrentPost(root string) *testresult.TestResult {
+	res := &testresult.TestResult{
+		Suite:   "concurrency",
+		Element: "repopulls/{id}/jobs",
+		ID:      "POST x20 (operator, admin)",
+	}
+
+	url := fmt.Sprintf("%s/repopulls/%d/jobs", root, repoPullID)
+
+	// each job gets a unique kv value, so that we can check
+	// that every job was stored with its own config
+	fns := []func() response{}
+	for i := 0; i < numRequests; i++ {
+		body := fmt.Sprintf(`{"agent_id":1, "is_ready":false, "priorjob_ids":[], "config":{"kv": {"n": "%d"}}}`, i)
+		user := roles[i%len(roles)]
+		fns = append(fns, func() response { return request("POST", url, body, user) })
+	}
+	rs := fire(fns)
+
+	err := checkResponses(res, "1", rs, func(i int, code int) bool { return code == 201 })
+	if err != nil {
+		return res
+	}
+
+	// no two POSTs may have been given the same ID
+	created := map[uint32]int{}
+	for i, r := range rs {
+		id, err := createdID(r)
+		if err != nil {
+			utils.FailTest(res, "2", fmt.Errorf("request %d: %v", i+1, err))
+			return res
+		}
+		if prev, ok := created[id]; ok {
+			utils.FailTest(res, "2", fmt.Errorf("requests %d and %d were both given job ID %d", prev+1, i+1, id))
+			return res
+		}
+		created[id] = i
+	}
+
+	// and each must have been stored exactly once, with its config
+	jobs, err := utils.ListJobs(res, "3", root, repoPullID, "viewer")
+	if err != nil {
+		return res
+	}
+	if len(jobs) != numRequests {
+		utils.FailTest(res, "4", fmt.Errorf("expected %d jobs, got %d", numRequests, len(jobs)))
+		return res
+	}
+	for _, j := range jobs {
+		i, ok := created[j.ID]
+		if !ok {
+			utils.FailTest(res, "4", fmt.Errorf("job %d was not returned by any POST", j.ID))
+			return res
+		}
+		res.Wanted = fmt.Sprintf(`{"kv": {"n": "%d"}}`, i)
+		res.Got = j.Config
+		if !utils.IsMatch(res) {
+			utils.FailMatch(res, "4")
+			return res
+		}
+	}
+
+	utils.Pass(res)
+	return res
+}
+
+// ===== simultaneous PUT /jobs/id
+
+func concurrentPut(root string) *testresult.TestResult {
+	res := &testresult.TestResult{
+		Suite:   "concurrency",
+		Element: "jobs/{id}",
+		ID:      "PUT x20 (operator, admin, viewer)",
+	}
+
+	url := root + "/jobs/4"
+
+	// operators and admins alternate is_ready; every third
+	// request is from a viewer and must be denied
+	users := []string{}
+	fns := []func() response{}
+	for i := 0; i < numRequests; i++ {
+		body := fmt.Sprintf(`{"is_ready": %t}`, i%2 == 0)
+		user := roles[i%len(roles)]
+		if i%3 == 2 {
+			user = "viewer"
+		}
+		users = append(users, user)
+		fns = append(fns, func() response { return request("PUT", url, body, user) })
+	}
+	rs := fire(fns)
+
+	err := checkResponses(res, "1", rs, func(i int, code int) bool {
+		if users[i] == "viewer" {
+			return code == 403
+		}
+		return code == 204
+	})
+	if err != nil {
+		return res
+	}
+
+	// whichever PUT came last, nothing but is_ready may change
+	_, err = utils.GetJob(res, "2", root, 4, "viewer")
+	if err != nil {
+		return res
+	}
+	err = utils.CheckGoldenExcept(res, "3", "job.is_ready")
+	if err != nil {
+		return res
+	}
+
+	utils.Pass(res)
+	return res
+}
+
+// ===== simultaneous DELETE /jobs/id of the same job
+
+func concurrentDeleteSame(root string) *testresult.TestResult {
+	res := &testresult.TestResult{
+		Suite:   "concurrency",
+		Element: "jobs/{id}",
+		ID:      "DELETE x20 same job (admin)",
+	}
+
+	url := root + "/jobs/2"
+
+	fns := []func() response{}
+	for i := 0; i < numRequests; i++ {
+		fns = append(fns, func() response { return request("DELETE", url, ``, "admin") })
+	}
+	rs := fire(fns)
+
+	// exactly one DELETE may succeed; the rest must find it gone
+	err := checkResponses(res, "1", rs, func(i int, code int) bool { return code == 204 || code == 404 })
+	if err != nil {
+		return res
+	}
+	succeeded := 0
+	for _, r := range rs {
+		if r.code == 204 {
+			succeeded++
+		}
+	}
+	if succeeded != 1 {
+		utils.FailTest(res, "2", fmt.Errorf("expected exactly 1 successful DELETE, got %d", succeeded))
+		return res
+	}
+
+	err = utils.GetContent(res, "3", url, 404, "viewer")
+	if err != nil {
+		return res
+	}
+
+	jobs, err := utils.ListJobs(res, "4", root, fixtureRepoPullID, "viewer")
+	if err != nil {
+		return res
+	}
+	err = checkNoOrphans(res, "5", root, jobs)
+	if err != nil {
+		return res
+	}
+
+	utils.Pass(res)
+	return res
+}
+
+// ===== simultaneous DELETE /jobs/id and POST /repopulls/id/jobs
+
+func concurrentDeleteAndPost(root string) *testresult.TestResult {
+	res := &testresult.TestResult{
+		Suite:   "concurrency",
+		Element: "jobs",
+		ID:      "DELETE and POST with priors (admin, operator)",
+	}
+
+	// first, create some jobs to delete and to use as priors
+	base := []uint32{}
+	for i := 0; i < numRequests/2; i++ {
+		id, err := utils.CreateJob(res, "1", root, repoPullID, 1, nil, `{}`, false, "operator")
+		if err != nil {
+			return res
+		}
+		base = append(base, id)
+	}
+
+	// now, delete each base job while simultaneously creating
+	// a new job that lists it as a prior job
+	deleteOf := map[int]uint32{}
+	postOf := map[int]uint32{}
+	fns := []func() response{}
+	for _, id := range base {
+		delURL := fmt.Sprintf("%s/jobs/%d", root, id)
+		deleteOf[len(fns)] = id
+		fns = append(fns, func() response { return request("DELETE", delURL, ``, "admin") })
+
+		postURL := fmt.Sprintf("%s/repopulls/%d/jobs", root, repoPullID)
+		body := fmt.Sprintf(`{"agent_id":1, "is_ready":false, "priorjob_ids":[%d], "config":{}}`, id)
+		postOf[len(fns)] = id
+		fns = append(fns, func() response { return request("POST", postURL, body, "operator") })
+	}
+	rs := fire(fns)
+
+	// every DELETE must succeed, since nothing else deletes;
+	// each POST may succeed or be rejected for a missing prior
+	err := checkResponses(res, "2", rs, func(i int, code int) bool {
+		if _, ok := deleteOf[i]; ok {
+			return code == 204
+		}
+		return code == 201 || code == 400 || code == 404
+	})
+	if err != nil {
+		return res
+	}
+
+	// in any serial order, the remaining jobs are exactly the
+	// successfully created ones, since every base job is gone
+	wantIDs := map[uint32]bool{}
+	for i, r := range rs {
+		if _, ok := postOf[i]; ok && r.code == 201 {
+			id, err := createdID(r)
+			if err != nil {
+				utils.FailTest(res, "3", fmt.Errorf("request %d: %v", i+1, err))
+				return res
+			}
+			if wantIDs[id] {
+				utils.FailTest(res, "3", fmt.Errorf("job ID %d was returned by more than one POST", id))
+				return res
+			}
+			wantIDs[id] = true
+		}
+	}
+
+	jobs, err := utils.ListJobs(res, "4", root, repoPullID, "viewer")
+	if err != nil {
+		return res
+	}
+	for _, j := range jobs {
+		if !wantIDs[j.ID] {
+			utils.FailTest(res, "5", fmt.Errorf("job %d should not exist after all DELETEs and POSTs", j.ID))
+			return res
+		}
+		delete(wantIDs, j.ID)
+	}
+	for id := range wantIDs {
+		utils.FailTest(res, "5", fmt.Errorf("job %d was created but is missing", id))
+		return res
+	}
+
+	err = checkNoOrphans(res, "6", root, jobs)
+	if err != nil {
+		return res
+	}
+
+	utils.Pass(res)
+	return res
+}
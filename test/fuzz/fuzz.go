@@ -0,0 +1,192 @@
+// SPDX-License-Identifier: Apache-2.0 OR GPL-2.0-or-later
+
+// Package fuzz sends mutated request bodies to each of the
+// API's POST and PUT endpoints, and flags any response that
+// suggests the API mishandled the input.
+package fuzz
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"math/rand"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/swinslow/peridot-jobrunner-testing/internal/fstree"
+	"github.com/swinslow/peridot-jobrunner-testing/internal/testresult"
+	"github.com/swinslow/peridot-jobrunner-testing/test/utils"
+)
+
+// Config controls the fuzz tests.
+type Config struct {
+	// Iterations is the number of mutated bodies to send to
+	// each endpoint. If zero, no fuzz tests are run.
+	Iterations int
+
+	// Seed is the random seed. Each endpoint's inputs are
+	// generated from Seed and the endpoint's index, so that
+	// any failure can be reproduced with the same Seed.
+	Seed int64
+
+	// Timeout is how long to wait for each response before
+	// treating the request as hung.
+	Timeout time.Duration
+
+	// Dir is the directory where failing inputs are saved.
+	Dir string
+}
+
+// endpoint is one POST or PUT endpoint, with a valid body that
+// is mutated to generate inputs.
+type endpoint struct {
+	method string
+	path   string
+	user   string
+	valid  string
+}
+
+var endpoints = []endpoint{
+	{"POST", "/users", "admin", `{"name": "Fuzz User", "github": "fuzz", "access": "viewer"}`},
+	{"POST", "/projects", "operator", `{"name": "fuzz", "fullname": "fuzz project"}`},
+	{"POST", "/subprojects", "operator", `{"project_id": 1, "name": "fuzzsp", "fullname": "fuzz subproject"}`},
+	{"POST", "/repos", "operator", `{"subproject_id": 1, "name": "fuzzrepo", "address": "https://github.com/swinslow/fuzzrepo.git"}`},
+	{"POST", "/repos/1/branches", "operator", `{"branch": "fuzz"}`},
+	{"POST", "/repos/1/branches/master", "operator", `{"commit": "b3b725b5cb5f30a27d7c53756831e788457ca16c"}`},
+	{"POST", "/agents", "operator", `{"name":"fuzz", "is_active":true, "address":"https://agent-fuzz", "port":3011, "is_codereader":false, "is_spdxreader":false, "is_codewriter":false, "is_spdxwriter":false}`},
+	{"POST", "/repopulls/3/jobs", "operator", `{"agent_id":1, "is_ready":false, "priorjob_ids":[], "config":{"kv": {"hello": "world"}}}`},
+	{"PUT", "/jobs/4", "operator", `{"is_ready": true}`},
+	{"POST", "/admin/db", "viewer", `{"command": "resetDB"}`},
+}
+
+// GetTests returns one fuzz test for each endpoint.
+func GetTests(cfg Config) []testresult.Test {
+	allTests := []testresult.Test{}
+	if cfg.Iterations <= 0 {
+		return allTests
+	}
+
+	for i, e := range endpoints {
+		allTests = append(allTests, testresult.Test{
+			Func: makeFuzzTest(cfg, e, cfg.Seed+int64(i)),
+			Tags: []string{"destructive", "slow", "fuzz"},
+		})
+	}
+	return allTests
+}
+
+// makeFuzzTest returns a TestFunc that sends cfg.Iterations
+// mutated bodies to e. It keeps going after a failing input so
+// that every failing input for the endpoint is saved, and
+// reports the first one.
+func makeFuzzTest(cfg Config, e endpoint, seed int64) testresult.TestFunc {
+	return func(root string) *testresult.TestResult {
+		res := &testresult.TestResult{
+			Suite:   "fuzz",
+			Element: e.path,
+			ID:      e.method,
+		}
+
+		// a dry run sends nothing, so there is nothing to fuzz
+		if utils.DryRun {
+			utils.Pass(res)
+			return res
+		}
+
+		r := rand.New(rand.NewSource(seed))
+		client := &http.Client{Transport: utils.Transport, Timeout: cfg.Timeout}
+		failures := 0
+
+		for i := 0; i < cfg.Iterations; i++ {
+			m := mutators[r.Intn(len(mutators))]
+			body, desc := m(r, e.valid)
+
+			problem, got := send(client, root, e, body)
+			if problem == "" {
+				continue
+			}
+
+			failures++
+			saved, err := save(cfg.Dir, e, i, body, desc, problem)
+			if err != nil {
+				saved = fmt.Sprintf("(could not save input: %v)", err)
+			}
+			if failures == 1 {
+				res.Got = got
+				utils.FailTest(res, fmt.Sprintf("%d", i+1), fmt.Errorf("%s after %s; input saved to %s", problem, desc, saved))
+			}
+		}
+
+		if failures > 0 {
+			res.FailError = fmt.Errorf("%d of %d inputs failed; first: %v", failures, cfg.Iterations, res.FailError)
+			return res
+		}
+
+		utils.Pass(res)
+		return res
+	}
+}
+
+// send makes the request and returns a description of what was
+// wrong with the response, or the empty string if nothing was.
+// It also returns the response body, if any.
+func send(client *http.Client, root string, e endpoint, body string) (string, []byte) {
+	req, err := http.NewRequest(e.method, root+e.path, strings.NewReader(body))
+	if err != nil {
+		return fmt.Sprintf("could not create request: %v", err), nil
+	}
+	req.Header.Set("Content-Type", "application/json")
+	utils.AddAuthHeader(nil, "", req, e.user)
+
+	resp, err := client.Do(req)
+	if err != nil {
+		if ue, ok := err.(interface{ Timeout() bool }); ok && ue.Timeout() {
+			return fmt.Sprintf("no response within %s", client.Timeout), nil
+		}
+		return fmt.Sprintf("request failed: %v", err), nil
+	}
+	defer resp.Body.Close()
+
+	b, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return fmt.Sprintf("error reading response body: %v", err), nil
+	}
+
+	if resp.StatusCode >= 500 {
+		return fmt.Sprintf("got HTTP status code %d", resp.StatusCode), b
+	}
+	if resp.StatusCode >= 400 && !json.Valid(b) {
+		return fmt.Sprintf("got non-JSON body with HTTP status code %d", resp.StatusCode), b
+	}
+
+	return "", b
+}
+
+// save writes a failing input to dir, as a .body file holding
+// the exact request body and a .txt file describing the request
+// and the failure. It returns the path to the .txt file.
+func save(dir string, e endpoint, iteration int, body string, desc string, problem string) (string, error) {
+	err := os.MkdirAll(dir, 0755)
+	if err != nil {
+		return "", err
+	}
+
+	name := fmt.Sprintf("%s-%s-%d", e.method, fstree.SafeName(e.path), iteration+1)
+	bodyPath := filepath.Join(dir, name+".body")
+	err = ioutil.WriteFile(bodyPath, []byte(body), 0644)
+	if err != nil {
+		return "", err
+	}
+
+	txtPath := filepath.Join(dir, name+".txt")
+	txt := fmt.Sprintf("%s %s\nUser: %s\nMutation: %s\nProblem: %s\nBody: %s (%d bytes)\n", e.method, e.path, e.user, desc, problem, bodyPath, len(body))
+	err = ioutil.WriteFile(txtPath, []byte(txt), 0644)
+	if err != nil {
+		return "", err
+	}
+
+	return txtPath, nil
+}
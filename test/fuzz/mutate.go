@@ -0,0 +1,193 @@
+// SPDX-License-Identifier: Apache-2.0 OR GPL-2.0-or-later
+
+package fuzz
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"math/rand"
+	"sort"
+	"strings"
+)
+
+// mutator turns a valid JSON request body into a mutated one,
+// and returns a short description of what it did.
+type mutator func(r *rand.Rand, valid string) (string, string)
+
+// mutators are chosen from at random for each fuzz input.
+var mutators = []mutator{
+	truncate,
+	corruptByte,
+	dropBrace,
+	wrongType,
+	unicodeEdge,
+	hugeString,
+	deepNesting,
+	extraKeys,
+	notAnObject,
+}
+
+// truncate cuts the body off at a random point.
+func truncate(r *rand.Rand, valid string) (string, string) {
+	n := r.Intn(len(valid))
+	return valid[:n], fmt.Sprintf("truncate at %d", n)
+}
+
+// corruptByte replaces one byte with a random byte, which may
+// not be valid UTF-8.
+func corruptByte(r *rand.Rand, valid string) (string, string) {
+	b := []byte(valid)
+	i := r.Intn(len(b))
+	c := byte(r.Intn(256))
+	b[i] = c
+	return string(b), fmt.Sprintf("corrupt byte %d to 0x%02x", i, c)
+}
+
+// dropBrace removes one of the braces or brackets.
+func dropBrace(r *rand.Rand, valid string) (string, string) {
+	idxs := []int{}
+	for i, c := range valid {
+		if strings.ContainsRune("{}[]", c) {
+			idxs = append(idxs, i)
+		}
+	}
+	if len(idxs) == 0 {
+		return truncate(r, valid)
+	}
+	i := idxs[r.Intn(len(idxs))]
+	return valid[:i] + valid[i+1:], fmt.Sprintf("drop %q at %d", valid[i], i)
+}
+
+// wrongTypeValues replace a field's value in wrongType.
+var wrongTypeValues = []interface{}{
+	nil, true, 0, -1, 1.5, 4294967296, "", "x", []interface{}{}, map[string]interface{}{},
+}
+
+// wrongType replaces the value of one top-level field with a
+// value of a (probably) different type.
+func wrongType(r *rand.Rand, valid string) (string, string) {
+	obj, keys := parseObject(valid)
+	if len(keys) == 0 {
+		return truncate(r, valid)
+	}
+	k := keys[r.Intn(len(keys))]
+	v := wrongTypeValues[r.Intn(len(wrongTypeValues))]
+	obj[k] = v
+	return marshal(obj), fmt.Sprintf("set %q to %#v", k, v)
+}
+
+// unicodeValues are strings that are troublesome for parsers,
+// databases or terminals. Some are only representable as
+// escapes inside JSON, so they are inserted raw.
+var unicodeValues = []string{
+	`"\u0000"`,
+	`"\ud800"`,
+	`"\udfff\ud800"`,
+	"\"\u202egnp.exe\"",
+	"\"\U0001F600\U0001F4A9\"",
+	"\"e\u0301\u0301\u0301\u0301\"",
+	"\"\ufeffbom\"",
+	"\"\xff\xfe\"",
+	"\"" + strings.Repeat("\u00e9", 1000) + "\"",
+}
+
+// unicodeEdge replaces the value of one top-level field with a
+// unicode edge case.
+func unicodeEdge(r *rand.Rand, valid string) (string, string) {
+	obj, keys := parseObject(valid)
+	if len(keys) == 0 {
+		return truncate(r, valid)
+	}
+	k := keys[r.Intn(len(keys))]
+	i := r.Intn(len(unicodeValues))
+	obj[k] = "__UNICODE__"
+	s := strings.Replace(marshal(obj), `"__UNICODE__"`, unicodeValues[i], 1)
+	return s, fmt.Sprintf("set %q to unicode value #%d", k, i)
+}
+
+// hugeString replaces the value of one top-level field with a
+// string of up to 8 MiB.
+func hugeString(r *rand.Rand, valid string) (string, string) {
+	obj, keys := parseObject(valid)
+	if len(keys) == 0 {
+		return truncate(r, valid)
+	}
+	k := keys[r.Intn(len(keys))]
+	n := 1 << uint(16+r.Intn(8))
+	obj[k] = strings.Repeat("a", n)
+	return marshal(obj), fmt.Sprintf("set %q to %d-byte string", k, n)
+}
+
+// deepNesting replaces the value of one top-level field with
+// deeply nested arrays or objects.
+func deepNesting(r *rand.Rand, valid string) (string, string) {
+	obj, keys := parseObject(valid)
+	if len(keys) == 0 {
+		return truncate(r, valid)
+	}
+	k := keys[r.Intn(len(keys))]
+	depth := 1000 * (1 + r.Intn(100))
+	var nested string
+	if r.Intn(2) == 0 {
+		nested = strings.Repeat("[", depth) + strings.Repeat("]", depth)
+	} else {
+		nested = strings.Repeat(`{"a":`, depth) + "1" + strings.Repeat("}", depth)
+	}
+	obj[k] = "__NESTED__"
+	s := strings.Replace(marshal(obj), `"__NESTED__"`, nested, 1)
+	return s, fmt.Sprintf("set %q to depth-%d nesting", k, depth)
+}
+
+// extraKeys adds many unknown keys and a duplicate key.
+func extraKeys(r *rand.Rand, valid string) (string, string) {
+	obj, keys := parseObject(valid)
+	n := 1 + r.Intn(10000)
+	for i := 0; i < n; i++ {
+		obj[fmt.Sprintf("k%d", i)] = i
+	}
+	s := marshal(obj)
+	if len(keys) > 0 {
+		// JSON objects with duplicate keys are technically valid
+		s = strings.TrimSuffix(s, "}") + fmt.Sprintf(`,%q:null}`, keys[0])
+	}
+	return s, fmt.Sprintf("add %d unknown keys and a duplicate key", n)
+}
+
+// notAnObjectValues replace the whole body in notAnObject.
+var notAnObjectValues = []string{
+	``, `null`, `0`, `"x"`, `[]`, `[{}]`, `{}`, `{}{}`, `{"a":1}garbage`,
+}
+
+// notAnObject replaces the whole body with something that is
+// not a single JSON object.
+func notAnObject(r *rand.Rand, valid string) (string, string) {
+	i := r.Intn(len(notAnObjectValues))
+	return notAnObjectValues[i], fmt.Sprintf("replace body with %q", notAnObjectValues[i])
+}
+
+// parseObject parses valid as a JSON object, and returns it
+// with its keys in sorted order.
+func parseObject(valid string) (map[string]interface{}, []string) {
+	obj := map[string]interface{}{}
+	d := json.NewDecoder(strings.NewReader(valid))
+	d.UseNumber()
+	if d.Decode(&obj) != nil {
+		return map[string]interface{}{}, nil
+	}
+	keys := []string{}
+	for k := range obj {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return obj, keys
+}
+
+// marshal converts obj to JSON without escaping HTML characters.
+func marshal(obj map[string]interface{}) string {
+	var buf bytes.Buffer
+	e := json.NewEncoder(&buf)
+	e.SetEscapeHTML(false)
+	e.Encode(obj)
+	return strings.TrimSuffix(buf.String(), "\n")
+}
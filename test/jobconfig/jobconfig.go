@@ -0,0 +1,154 @@
+// SPDX-License-Identifier: Apache-2.0 OR GPL-2.0-or-later
+
+package jobconfig
+
+import (
+	"fmt"
+
+	"github.com/swinslow/peridot-jobrunner-testing/internal/testresult"
+	"github.com/swinslow/peridot-jobrunner-testing/test/utils"
+)
+
+// expected holds the HTTP status code that the API should
+// return for each generated mutation of the base config: 201
+// if the config should be accepted, or 400 if it should be
+// rejected.
+var expected = map[string]int{
+	"codereader: delete":                        201,
+	"codereader: wrong type":                    400,
+	"codereader: null":                          400,
+	"codereader.godeps: delete":                 201,
+	"codereader.godeps: wrong type":             400,
+	"codereader.godeps: null":                   400,
+	"codereader.godeps.priorjob_id: delete":     400,
+	"codereader.godeps.priorjob_id: wrong type": 400,
+	"codereader.godeps.priorjob_id: null":       400,
+	"codereader.primary: delete":                201,
+	"codereader.primary: wrong type":            400,
+	"codereader.primary: null":                  400,
+	"codereader.primary.path: delete":           400,
+	"codereader.primary.path: wrong type":       400,
+	"codereader.primary.path: null":             400,
+	"kv: delete":                                201,
+	"kv: wrong type":                            400,
+	"kv: null":                                  400,
+	"kv.hello: delete":                          201,
+	"kv.hello: wrong type":                      400,
+	"kv.hello: null":                            400,
+	"spdxreader: delete":                        201,
+	"spdxreader: wrong type":                    400,
+	"spdxreader: null":                          400,
+	"spdxreader.primary: delete":                201,
+	"spdxreader.primary: wrong type":            400,
+	"spdxreader.primary: null":                  400,
+	"spdxreader.primary.path: delete":           400,
+	"spdxreader.primary.path: wrong type":       400,
+	"spdxreader.primary.path: null":             400,
+	"(root): unknown key":                       400,
+	"codereader: unknown key":                   400,
+	"codereader.godeps: unknown key":            400,
+	"codereader.primary: unknown key":           400,
+	"kv: unknown key":                           201,
+	"spdxreader: unknown key":                   400,
+	"spdxreader.primary: unknown key":           400,
+}
+
+// priorJobID is the ID that the prior job created by each test
+// gets, which the configs and priorjob_ids refer to.
+const priorJobID = 5
+
+// configCase is one config to POST, with the priorjob_ids sent
+// alongside it and the expected HTTP status code.
+type configCase struct {
+	name        string
+	priorJobIDs string
+	config      string
+	code        int
+}
+
+// extraCases are hand-written cases for relationships between
+// the config and the rest of the job, which the generated
+// mutations do not cover.
+var extraCases = []configCase{
+	{"base config", `[5]`, baseConfig, 201},
+	{"empty config", `[]`, `{}`, 201},
+	{"priorjob_id not in priorjob_ids", `[]`, baseConfig, 400},
+	{"priorjob_id does not exist", `[5]`, `{"codereader": {"godeps": {"priorjob_id": 99}}}`, 400},
+	{"input with both path and priorjob_id", `[5]`, `{"codereader": {"primary": {"path": "/somewhere", "priorjob_id": 5}}}`, 400},
+	{"config is not an object", `[5]`, `"codereader"`, 400},
+}
+
+// GetTests returns all of the job config validation tests.
+func GetTests() []testresult.Test {
+	allTests := []testresult.Test{}
+	tags := []string{"destructive", "jobs", "config"}
+
+	for _, c := range extraCases {
+		allTests = append(allTests, testresult.Test{Func: makeConfigTest(c), Tags: tags})
+	}
+
+	for _, m := range generateMutations() {
+		c := configCase{m.name, `[5]`, m.config, expected[m.name]}
+		allTests = append(allTests, testresult.Test{Func: makeConfigTest(c), Tags: tags})
+	}
+
+	return allTests
+}
+
+// makeConfigTest returns a TestFunc that creates a prior job
+// and then POSTs a job with the case's config, checking whether
+// it was accepted or rejected as expected. A code of 0 means
+// the case has no entry in the expected table, which is always
+// reported as a failure, without sending anything, so that the
+// table is kept complete.
+func makeConfigTest(c configCase) testresult.TestFunc {
+	return func(root string) *testresult.TestResult {
+		res := &testresult.TestResult{
+			Suite:   "endpoints",
+			Element: "repopulls/{id}/jobs config",
+			ID:      "POST (" + c.name + ")",
+		}
+
+		if c.code == 0 {
+			utils.FailTest(res, "0", fmt.Errorf("no expected outcome for %q in jobconfig table", c.name))
+			return res
+		}
+
+		url := root + "/repopulls/3/jobs"
+
+		// first, create the prior job that configs refer to
+		body := `{"agent_id":1, "is_ready":false, "priorjob_ids":[], "config":{}}`
+		err := utils.Post(res, "1", url, body, 201, "operator")
+		if err != nil {
+			return res
+		}
+
+		id, err := utils.ParseID(res, "2")
+		if err != nil {
+			return res
+		}
+		if id != priorJobID {
+			utils.FailTest(res, "2", fmt.Errorf("expected prior job to get ID %d, got %d", priorJobID, id))
+			return res
+		}
+
+		// now, send the job with the case's config
+		body = fmt.Sprintf(`{"agent_id":1, "is_ready":false, "priorjob_ids":%s, "config":%s}`, c.priorJobIDs, c.config)
+		err = utils.Post(res, "3", url, body, c.code, "operator")
+		if err != nil {
+			utils.FailTest(res, "3", fmt.Errorf("expected config to be %s: %v", outcome(c.code), err))
+			return res
+		}
+
+		utils.Pass(res)
+		return res
+	}
+}
+
+// outcome describes an HTTP status code as accepted or rejected.
+func outcome(code int) string {
+	if code >= 200 && code < 300 {
+		return "accepted"
+	}
+	return "rejected"
+}
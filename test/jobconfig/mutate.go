@@ -0,0 +1,140 @@
+// SPDX-License-Identifier: Apache-2.0 OR GPL-2.0-or-later
+
+package jobconfig
+
+import (
+	"encoding/json"
+	"sort"
+	"strings"
+)
+
+// baseConfig is a valid job config that exercises each kind of
+// input. Its godeps input refers to the prior job that each
+// test creates first, which is always job 5.
+const baseConfig = `{
+	"kv": {"hello": "world"},
+	"codereader": {"primary": {"path": "/somewhere"}, "godeps": {"priorjob_id": 5}},
+	"spdxreader": {"primary": {"path": "/path/wherever"}}
+}`
+
+// mutation is one systematically generated change to the
+// base config.
+type mutation struct {
+	// name identifies the mutation, e.g. "codereader.primary.path: delete".
+	name string
+
+	// config is the mutated config as a JSON string.
+	config string
+}
+
+// generateMutations returns, in a stable order, one mutation
+// for each of the following applied to every key in the base
+// config: deleting it, replacing its value with a value of the
+// wrong type, and replacing its value with null. It also adds
+// an unknown key to each object in the base config.
+func generateMutations() []mutation {
+	muts := []mutation{}
+
+	for _, p := range keyPaths(parseBase(), nil) {
+		name := strings.Join(p, ".")
+
+		cfg := parseBase()
+		delete(parentOf(cfg, p).(map[string]interface{}), p[len(p)-1])
+		muts = append(muts, mutation{name + ": delete", marshal(cfg)})
+
+		cfg = parseBase()
+		parent := parentOf(cfg, p).(map[string]interface{})
+		parent[p[len(p)-1]] = wrongType(parent[p[len(p)-1]])
+		muts = append(muts, mutation{name + ": wrong type", marshal(cfg)})
+
+		cfg = parseBase()
+		parentOf(cfg, p).(map[string]interface{})[p[len(p)-1]] = nil
+		muts = append(muts, mutation{name + ": null", marshal(cfg)})
+	}
+
+	objPaths := [][]string{{}}
+	for _, p := range keyPaths(parseBase(), nil) {
+		if _, ok := valueAt(parseBase(), p).(map[string]interface{}); ok {
+			objPaths = append(objPaths, p)
+		}
+	}
+	for _, p := range objPaths {
+		cfg := parseBase()
+		valueAt(cfg, p).(map[string]interface{})["bogus"] = "x"
+		name := strings.Join(p, ".")
+		if name == "" {
+			name = "(root)"
+		}
+		muts = append(muts, mutation{name + ": unknown key", marshal(cfg)})
+	}
+
+	return muts
+}
+
+// parseBase returns a fresh copy of the base config.
+func parseBase() map[string]interface{} {
+	var cfg map[string]interface{}
+	err := json.Unmarshal([]byte(baseConfig), &cfg)
+	if err != nil {
+		panic(err)
+	}
+	return cfg
+}
+
+// marshal converts a config back into a JSON string.
+func marshal(cfg map[string]interface{}) string {
+	b, err := json.Marshal(cfg)
+	if err != nil {
+		panic(err)
+	}
+	return string(b)
+}
+
+// keyPaths returns the path to every key in v, depth first,
+// in sorted order within each object.
+func keyPaths(v interface{}, prefix []string) [][]string {
+	obj, ok := v.(map[string]interface{})
+	if !ok {
+		return nil
+	}
+
+	keys := []string{}
+	for k := range obj {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	paths := [][]string{}
+	for _, k := range keys {
+		p := append(append([]string{}, prefix...), k)
+		paths = append(paths, p)
+		paths = append(paths, keyPaths(obj[k], p)...)
+	}
+	return paths
+}
+
+// valueAt returns the value at path p within cfg.
+func valueAt(cfg map[string]interface{}, p []string) interface{} {
+	var v interface{} = cfg
+	for _, k := range p {
+		v = v.(map[string]interface{})[k]
+	}
+	return v
+}
+
+// parentOf returns the object containing the last key of p.
+func parentOf(cfg map[string]interface{}, p []string) interface{} {
+	return valueAt(cfg, p[:len(p)-1])
+}
+
+// wrongType returns a value of a different JSON type than v.
+func wrongType(v interface{}) interface{} {
+	switch v.(type) {
+	case string:
+		return 17
+	case float64:
+		return "17"
+	default:
+		return "x"
+	}
+}
@@ -0,0 +1,145 @@
+// SPDX-License-Identifier: Apache-2.0 OR GPL-2.0-or-later
+
+package property
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"sort"
+
+	"github.com/swinslow/peridot-jobrunner-testing/test/utils"
+)
+
+// firstJobID is the ID that the API will assign to the first
+// job created after the fixtures are set up.
+const firstJobID = 5
+
+// modelJob is the expected state of one job.
+type modelJob struct {
+	id          uint32
+	agentID     uint32
+	priorJobIDs []uint32
+	isReady     bool
+	config      string
+}
+
+// model is the expected state of the jobs in repoPullID that
+// were created by an operation sequence.
+type model struct {
+	nextID uint32
+
+	// jobs maps an op's create key to the job it created.
+	// Deleted jobs are removed.
+	jobs map[int]*modelJob
+
+	// deleted maps an op's create key to the ID of the job
+	// it created, once that job has been deleted.
+	deleted map[int]uint32
+}
+
+func newModel() *model {
+	return &model{
+		nextID:  firstJobID,
+		jobs:    map[int]*modelJob{},
+		deleted: map[int]uint32{},
+	}
+}
+
+// deletedID returns the ID of the job created by the op with
+// the given key, if that job has since been deleted.
+func (m *model) deletedID(key int) (uint32, bool) {
+	id, ok := m.deleted[key]
+	return id, ok
+}
+
+// byID returns the live jobs, sorted by ID.
+func (m *model) byID() []*modelJob {
+	js := []*modelJob{}
+	for _, j := range m.jobs {
+		js = append(js, j)
+	}
+	sort.Slice(js, func(i, k int) bool { return js[i].id < js[k].id })
+	return js
+}
+
+// compareJob returns an error describing the first difference
+// between the expected job and the job returned by the API.
+func compareJob(want *modelJob, got *utils.Job) error {
+	if got.ID != want.id {
+		return fmt.Errorf("expected job ID %d, got %d", want.id, got.ID)
+	}
+	if got.RepoPullID != repoPullID {
+		return fmt.Errorf("job %d: expected repopull_id %d, got %d", want.id, repoPullID, got.RepoPullID)
+	}
+	if got.AgentID != want.agentID {
+		return fmt.Errorf("job %d: expected agent_id %d, got %d", want.id, want.agentID, got.AgentID)
+	}
+	if got.IsReady != want.isReady {
+		return fmt.Errorf("job %d: expected is_ready %t, got %t", want.id, want.isReady, got.IsReady)
+	}
+
+	wantPriors := append([]uint32{}, want.priorJobIDs...)
+	gotPriors := append([]uint32{}, got.PriorJobIDs...)
+	sort.Slice(wantPriors, func(i, k int) bool { return wantPriors[i] < wantPriors[k] })
+	sort.Slice(gotPriors, func(i, k int) bool { return gotPriors[i] < gotPriors[k] })
+	if fmt.Sprint(wantPriors) != fmt.Sprint(gotPriors) {
+		return fmt.Errorf("job %d: expected priorjob_ids %v, got %v", want.id, wantPriors, gotPriors)
+	}
+
+	equal, err := jsonEqual([]byte(want.config), got.Config)
+	if err != nil {
+		return fmt.Errorf("job %d: could not compare config: %v", want.id, err)
+	}
+	if !equal {
+		return fmt.Errorf("job %d: expected config %s, got %s", want.id, want.config, got.Config)
+	}
+
+	return nil
+}
+
+// compareJobs compares all expected jobs against the jobs
+// returned by the API for repoPullID.
+func compareJobs(want []*modelJob, got []*utils.Job) error {
+	if len(want) != len(got) {
+		ids := []uint32{}
+		for _, j := range got {
+			ids = append(ids, j.ID)
+		}
+		wantIDs := []uint32{}
+		for _, j := range want {
+			wantIDs = append(wantIDs, j.id)
+		}
+		return fmt.Errorf("expected jobs %v, got %v", wantIDs, ids)
+	}
+
+	sort.Slice(got, func(i, k int) bool { return got[i].ID < got[k].ID })
+	for i := range want {
+		err := compareJob(want[i], got[i])
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// jsonEqual reports whether a and b hold equivalent JSON values.
+func jsonEqual(a []byte, b []byte) (bool, error) {
+	if len(bytes.TrimSpace(b)) == 0 {
+		b = []byte(`{}`)
+	}
+	var av, bv interface{}
+	err := json.Unmarshal(a, &av)
+	if err != nil {
+		return false, err
+	}
+	err = json.Unmarshal(b, &bv)
+	if err != nil {
+		return false, err
+	}
+
+	ab, _ := json.Marshal(av)
+	bb, _ := json.Marshal(bv)
+	return bytes.Equal(ab, bb), nil
+}
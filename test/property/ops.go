@@ -0,0 +1,205 @@
+// SPDX-License-Identifier: Apache-2.0 OR GPL-2.0-or-later
+
+package property
+
+import (
+	"fmt"
+	"math/rand"
+	"strings"
+
+	"github.com/swinslow/peridot-jobrunner-testing/internal/testresult"
+	"github.com/swinslow/peridot-jobrunner-testing/test/utils"
+)
+
+// repoPullID is the fixture repo pull that operation sequences
+// create their jobs in. It has no jobs in the fixture state.
+const repoPullID = 3
+
+// nopAgentID is the fixture ID of the nop agent.
+const nopAgentID = 1
+
+type opKind int
+
+const (
+	opCreate opKind = iota
+	opSetReady
+	opDelete
+	opGet
+	opList
+)
+
+// op is one operation in a generated sequence. Jobs are
+// referred to by the key of the create op that made them, so
+// that removing ops while shrinking does not change which job
+// a later op refers to.
+type op struct {
+	kind opKind
+
+	// key identifies the job created by an opCreate.
+	key int
+
+	// ref is the key of the job that an opSetReady, opDelete
+	// or opGet acts on.
+	ref int
+
+	// priors holds the keys of an opCreate's prior jobs.
+	priors []int
+
+	// ready is the is_ready value for opCreate and opSetReady.
+	ready bool
+
+	// kv is the config kv value for opCreate.
+	kv string
+}
+
+func (o op) String() string {
+	switch o.kind {
+	case opCreate:
+		return fmt.Sprintf("create #%d (is_ready=%t, priors=%v, kv=%q)", o.key, o.ready, o.priors, o.kv)
+	case opSetReady:
+		return fmt.Sprintf("set #%d is_ready=%t", o.ref, o.ready)
+	case opDelete:
+		return fmt.Sprintf("delete #%d", o.ref)
+	case opGet:
+		return fmt.Sprintf("get #%d", o.ref)
+	case opList:
+		return "list"
+	}
+	return "unknown op"
+}
+
+// formatOps returns a numbered, one-per-line listing of ops.
+func formatOps(ops []op) string {
+	lines := []string{}
+	for i, o := range ops {
+		lines = append(lines, fmt.Sprintf("  %d: %s", i+1, o))
+	}
+	return strings.Join(lines, "\n")
+}
+
+// generateOps returns a random sequence of n valid operations.
+// Every op refers only to jobs that are live at that point in
+// the sequence, except for opGet, which sometimes refers to a
+// deleted job to check that it is really gone.
+func generateOps(r *rand.Rand, n int) []op {
+	ops := []op{}
+	live := []int{}
+	all := []int{}
+	nextKey := 1
+
+	pick := func(keys []int) int {
+		return keys[r.Intn(len(keys))]
+	}
+
+	for len(ops) < n {
+		roll := r.Intn(100)
+		switch {
+		case roll < 40 || len(live) == 0:
+			o := op{kind: opCreate, key: nextKey, ready: r.Intn(2) == 0, kv: fmt.Sprintf("v%d", r.Intn(1000))}
+			for _, k := range live {
+				if r.Intn(3) == 0 {
+					o.priors = append(o.priors, k)
+				}
+			}
+			nextKey++
+			live = append(live, o.key)
+			all = append(all, o.key)
+			ops = append(ops, o)
+		case roll < 60:
+			ops = append(ops, op{kind: opSetReady, ref: pick(live), ready: r.Intn(2) == 0})
+		case roll < 75:
+			i := r.Intn(len(live))
+			ops = append(ops, op{kind: opDelete, ref: live[i]})
+			live = append(live[:i], live[i+1:]...)
+		case roll < 85:
+			ops = append(ops, op{kind: opGet, ref: pick(all)})
+		default:
+			ops = append(ops, op{kind: opList})
+		}
+	}
+
+	return ops
+}
+
+// apply performs o against the API and the model, and checks
+// the API's response against the model. If o refers to a job
+// that does not exist in the model, which can happen after
+// shrinking, o is skipped. During a dry run there is no response
+// to check, so only the requests are planned.
+func (m *model) apply(res *testresult.TestResult, step string, root string, o op) error {
+	switch o.kind {
+	case opCreate:
+		priorIDs := []uint32{}
+		for _, k := range o.priors {
+			if j, ok := m.jobs[k]; ok {
+				priorIDs = append(priorIDs, j.id)
+			}
+		}
+		config := fmt.Sprintf(`{"kv": {"p": %q}}`, o.kv)
+		id, err := utils.CreateJob(res, step, root, repoPullID, nopAgentID, priorIDs, config, o.ready, "operator")
+		if err != nil {
+			return err
+		}
+		if id != m.nextID {
+			return fmt.Errorf("expected new job ID %d, got %d", m.nextID, id)
+		}
+		m.jobs[o.key] = &modelJob{id: id, agentID: nopAgentID, priorJobIDs: priorIDs, isReady: o.ready, config: config}
+		m.nextID++
+		return nil
+
+	case opSetReady:
+		j, ok := m.jobs[o.ref]
+		if !ok {
+			return nil
+		}
+		err := utils.SetJobReady(res, step, root, j.id, o.ready, "operator")
+		if err != nil {
+			return err
+		}
+		j.isReady = o.ready
+		return nil
+
+	case opDelete:
+		j, ok := m.jobs[o.ref]
+		if !ok {
+			return nil
+		}
+		url := fmt.Sprintf("%s/jobs/%d", root, j.id)
+		err := utils.Delete(res, step, url, ``, 204, "admin")
+		if err != nil {
+			return err
+		}
+		// deleting a job should not change any other job,
+		// including those that list it as a prior job
+		delete(m.jobs, o.ref)
+		m.deleted[o.ref] = j.id
+		return nil
+
+	case opGet:
+		j, ok := m.jobs[o.ref]
+		if !ok {
+			// o.ref was deleted, or its create op was shrunk
+			// away; only the former has a known ID to check
+			id, deleted := m.deletedID(o.ref)
+			if !deleted {
+				return nil
+			}
+			url := fmt.Sprintf("%s/jobs/%d", root, id)
+			return utils.GetContent(res, step, url, 404, "viewer")
+		}
+		got, err := utils.GetJob(res, step, root, j.id, "viewer")
+		if err != nil || utils.DryRun {
+			return err
+		}
+		return compareJob(j, got)
+
+	case opList:
+		got, err := utils.ListJobs(res, step, root, repoPullID, "viewer")
+		if err != nil || utils.DryRun {
+			return err
+		}
+		return compareJobs(m.byID(), got)
+	}
+
+	return nil
+}
@@ -0,0 +1,130 @@
+// SPDX-License-Identifier: Apache-2.0 OR GPL-2.0-or-later
+
+// Package property runs randomly generated sequences of job
+// operations against the API, checking each response against
+// an in-memory model of the expected state, and shrinks any
+// failing sequence to a minimal reproduction.
+package property
+
+import (
+	"fmt"
+	"math/rand"
+
+	"github.com/swinslow/peridot-jobrunner-testing/internal/testresult"
+	"github.com/swinslow/peridot-jobrunner-testing/test/utils"
+)
+
+// maxShrinkRuns limits how many candidate sequences are re-run
+// while shrinking a failing sequence.
+const maxShrinkRuns = 200
+
+// Config controls the generated operation sequences.
+type Config struct {
+	// Runs is the number of sequences to generate; each one
+	// is a separate test.
+	Runs int
+
+	// Steps is the number of operations in each sequence.
+	Steps int
+
+	// Seed is the random seed for the first sequence. Later
+	// sequences use Seed+1, Seed+2, and so on, so that any
+	// single failing sequence can be regenerated from its seed.
+	Seed int64
+
+	// Reset returns the database to the fixture state. It is
+	// called before each re-run while shrinking.
+	Reset func() error
+}
+
+// GetTests returns one test for each sequence described by cfg.
+func GetTests(cfg Config) []testresult.Test {
+	allTests := []testresult.Test{}
+	for i := 0; i < cfg.Runs; i++ {
+		allTests = append(allTests, testresult.Test{
+			Func: makeSequenceTest(cfg, cfg.Seed+int64(i)),
+			Tags: []string{"destructive", "jobs", "slow", "property"},
+		})
+	}
+	return allTests
+}
+
+// makeSequenceTest returns a TestFunc that generates and runs
+// the sequence for seed, shrinking it if it fails.
+func makeSequenceTest(cfg Config, seed int64) testresult.TestFunc {
+	return func(root string) *testresult.TestResult {
+		res := &testresult.TestResult{
+			Suite:   "property",
+			Element: "jobs",
+			ID:      fmt.Sprintf("seed %d", seed),
+		}
+
+		ops := generateOps(rand.New(rand.NewSource(seed)), cfg.Steps)
+
+		// the DB is already in the fixture state for the first run
+		failed, failIndex := runOps(res, root, ops)
+		if !failed {
+			utils.Pass(res)
+			return res
+		}
+
+		// a dry run's failures are not real, so are not shrunk
+		if utils.DryRun {
+			return res
+		}
+
+		// keep the first failure's details, and shrink the
+		// sequence up to and including the failing op
+		minimal := shrink(root, cfg.Reset, ops[:failIndex+1])
+		res.FailError = fmt.Errorf("%v\nminimal failing sequence (seed %d):\n%s", res.FailError, seed, formatOps(minimal))
+		return res
+	}
+}
+
+// runOps applies ops in order to a fresh model, stopping at the
+// first failure. It returns whether any op failed and, if so,
+// the failing op's index. The failure is recorded in res.
+func runOps(res *testresult.TestResult, root string, ops []op) (bool, int) {
+	m := newModel()
+	for i, o := range ops {
+		step := fmt.Sprintf("%d", i+1)
+		err := m.apply(res, step, root, o)
+		if err != nil {
+			res.Success = false
+			res.FailStep = step
+			res.FailError = fmt.Errorf("%s: %v", o, err)
+			return true, i
+		}
+	}
+	return false, 0
+}
+
+// shrink repeatedly removes single ops from a failing sequence
+// for as long as the result still fails, resetting the database
+// before each attempt. It returns the smallest failing sequence
+// that it found.
+func shrink(root string, reset func() error, ops []op) []op {
+	runs := 0
+	fails := func(candidate []op) bool {
+		runs++
+		if reset == nil || reset() != nil {
+			return false
+		}
+		scratch := &testresult.TestResult{}
+		failed, _ := runOps(scratch, root, candidate)
+		return failed
+	}
+
+	for changed := true; changed && runs < maxShrinkRuns; {
+		changed = false
+		for i := len(ops) - 1; i >= 0 && runs < maxShrinkRuns; i-- {
+			candidate := append(append([]op{}, ops[:i]...), ops[i+1:]...)
+			if fails(candidate) {
+				ops = candidate
+				changed = true
+			}
+		}
+	}
+
+	return ops
+}
@@ -0,0 +1,122 @@
+// SPDX-License-Identifier: Apache-2.0 OR GPL-2.0-or-later
+
+package scheduling
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/swinslow/peridot-jobrunner-testing/internal/testresult"
+	"github.com/swinslow/peridot-jobrunner-testing/test/utils"
+)
+
+// ===== linear chain: A -> B -> C
+
+func dagChain(root string) *testresult.TestResult {
+	res := &testresult.TestResult{
+		Suite:   "jobrunner",
+		Element: "scheduling",
+		ID:      "chain",
+	}
+
+	return runDAG(res, root, []dagNode{
+		{agentID: nopAgentID},
+		{agentID: nopAgentID, priors: []int{0}},
+		{agentID: nopAgentID, priors: []int{1}},
+	})
+}
+
+// ===== diamond: A -> (B, C) -> D
+
+func dagDiamond(root string) *testresult.TestResult {
+	res := &testresult.TestResult{
+		Suite:   "jobrunner",
+		Element: "scheduling",
+		ID:      "diamond",
+	}
+
+	return runDAG(res, root, []dagNode{
+		{agentID: nopAgentID},
+		{agentID: nopAgentID, priors: []int{0}},
+		{agentID: nopAgentID, priors: []int{0}},
+		{agentID: nopAgentID, priors: []int{1, 2}},
+	})
+}
+
+// ===== failed prior job: F (fails) -> D
+
+// blockedGrace is how long to keep watching a dependent job
+// after its prior job has failed.
+const blockedGrace = 10 * time.Second
+
+func dagFailedPrior(root string) *testresult.TestResult {
+	res := &testresult.TestResult{
+		Suite:   "jobrunner",
+		Element: "scheduling",
+		ID:      "failed prior",
+	}
+
+	// first, register an agent that the jobrunner cannot reach,
+	// so that any job using it will fail
+	body := `{"name":"unreachable", "is_active":true, "address":"https://agent-unreachable", "port":3099, "is_codereader":false, "is_spdxreader":false, "is_codewriter":false, "is_spdxwriter":false}`
+	err := utils.Post(res, "1", root+"/agents", body, 201, "operator")
+	if err != nil {
+		return res
+	}
+	badAgentID, err := utils.ParseID(res, "1")
+	if err != nil {
+		return res
+	}
+
+	// build F -> D, with D on the working nop agent
+	ids, err := buildDAG(res, "2", root, []dagNode{
+		{agentID: badAgentID},
+		{agentID: nopAgentID, priors: []int{0}},
+	})
+	if err != nil {
+		return res
+	}
+	failedID, dependentID := ids[0], ids[1]
+
+	err = markReady(res, "3", root, ids)
+	if err != nil {
+		return res
+	}
+
+	// wait for F to fail
+	failed, err := utils.WaitForJob(res, "4", root, failedID, "stopped", "error", jobTimeout, "operator")
+	if err != nil {
+		return res
+	}
+
+	// D must either never start, or be stopped with an error
+	// without having started before F finished
+	deadline := time.Now().Add(blockedGrace)
+	for time.Now().Before(deadline) {
+		dep, err := utils.GetJob(res, "5", root, dependentID, "operator")
+		if err != nil {
+			return res
+		}
+
+		if !dep.StartedAt.IsZero() && dep.StartedAt.Before(failed.FinishedAt) {
+			utils.FailTest(res, "5", fmt.Errorf("job %d started at %v, before failed prior job %d finished at %v", dependentID, dep.StartedAt, failedID, failed.FinishedAt))
+			return res
+		}
+		if dep.Status == "stopped" {
+			if dep.Health != "error" {
+				utils.FailTest(res, "5", fmt.Errorf("job %d stopped with health %s despite failed prior job %d, wanted it blocked or error", dependentID, dep.Health, failedID))
+				return res
+			}
+			break
+		}
+		if dep.Status != "startup" {
+			utils.FailTest(res, "5", fmt.Errorf("job %d reached status %s despite failed prior job %d, wanted it blocked or error", dependentID, dep.Status, failedID))
+			return res
+		}
+
+		time.Sleep(500 * time.Millisecond)
+	}
+
+	utils.Pass(res)
+	return res
+}
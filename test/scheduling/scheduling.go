@@ -0,0 +1,156 @@
+// SPDX-License-Identifier: Apache-2.0 OR GPL-2.0-or-later
+
+package scheduling
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/swinslow/peridot-jobrunner-testing/internal/testresult"
+	"github.com/swinslow/peridot-jobrunner-testing/test/utils"
+)
+
+// repoPullID is the fixture repo pull that the scenarios build
+// their job DAGs on. It has no jobs in the fixture state.
+const repoPullID = 3
+
+// nopAgentID is the fixture ID of the nop agent.
+const nopAgentID = 1
+
+// jobTimeout is how long to wait for each job to finish.
+const jobTimeout = 60 * time.Second
+
+// GetTests returns all of the jobrunner scheduling test suites.
+func GetTests() []testresult.Test {
+	tags := []string{"destructive", "jobs", "slow", "agent:nop"}
+	return []testresult.Test{
+		{Func: dagChain, Tags: tags},
+		{Func: dagDiamond, Tags: tags},
+		{Func: dagFailedPrior, Tags: tags},
+	}
+}
+
+// GetHooks returns the hooks for the scheduling tests. After a
+// test fails, they record the jobs in its repo pull, as the
+// jobrunner left them.
+func GetHooks() testresult.Hooks {
+	return testresult.Hooks{
+		AfterEach: func(root string, res *testresult.TestResult) error {
+			if res.Success {
+				return nil
+			}
+			return utils.DumpJobs(res, root, repoPullID)
+		},
+	}
+}
+
+// dagNode describes one job in a scenario's DAG. Priors holds
+// indexes into the scenario's slice of dagNodes, so that nodes
+// can refer to each other before their job IDs are known.
+type dagNode struct {
+	agentID uint32
+	priors  []int
+}
+
+// buildDAG creates the jobs for nodes, in order, with is_ready
+// set to false, and returns their job IDs. The step values
+// used are prefixed with stepPrefix.
+func buildDAG(res *testresult.TestResult, stepPrefix string, root string, nodes []dagNode) ([]uint32, error) {
+	ids := make([]uint32, len(nodes))
+	for i, n := range nodes {
+		priorIDs := []uint32{}
+		for _, p := range n.priors {
+			priorIDs = append(priorIDs, ids[p])
+		}
+		step := fmt.Sprintf("%s.%d", stepPrefix, i+1)
+		id, err := utils.CreateJob(res, step, root, repoPullID, n.agentID, priorIDs, `{}`, false, "operator")
+		if err != nil {
+			return nil, err
+		}
+		ids[i] = id
+	}
+
+	return ids, nil
+}
+
+// markReady sets is_ready for each job, in reverse order so
+// that dependents are always ready before their prior jobs.
+// This ensures that any ordering observed afterwards comes from
+// the jobrunner and not from the order of the PUT calls.
+func markReady(res *testresult.TestResult, stepPrefix string, root string, ids []uint32) error {
+	for i := len(ids) - 1; i >= 0; i-- {
+		step := fmt.Sprintf("%s.%d", stepPrefix, len(ids)-i)
+		err := utils.SetJobReady(res, step, root, ids[i], true, "operator")
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// checkOrdering confirms that no job in jobs started before
+// all of its prior jobs had finished. On failure, it fills in
+// the failure code in the TestResult and returns an error.
+func checkOrdering(res *testresult.TestResult, step string, jobs []*utils.Job) error {
+	byID := map[uint32]*utils.Job{}
+	for _, j := range jobs {
+		byID[j.ID] = j
+	}
+
+	for _, j := range jobs {
+		for _, pid := range j.PriorJobIDs {
+			prior, ok := byID[pid]
+			if !ok {
+				err := fmt.Errorf("job %d has unknown prior job %d", j.ID, pid)
+				utils.FailTest(res, step, err)
+				return err
+			}
+			if prior.FinishedAt.IsZero() {
+				err := fmt.Errorf("job %d started but prior job %d never finished", j.ID, pid)
+				utils.FailTest(res, step, err)
+				return err
+			}
+			if j.StartedAt.Before(prior.FinishedAt) {
+				err := fmt.Errorf("job %d started at %v, before prior job %d finished at %v", j.ID, j.StartedAt, pid, prior.FinishedAt)
+				utils.FailTest(res, step, err)
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+// runDAG builds the DAG described by nodes, marks it ready,
+// waits for every job to finish successfully and then checks
+// that the prior job ordering was honored.
+func runDAG(res *testresult.TestResult, root string, nodes []dagNode) *testresult.TestResult {
+	ids, err := buildDAG(res, "1", root, nodes)
+	if err != nil {
+		return res
+	}
+
+	err = markReady(res, "2", root, ids)
+	if err != nil {
+		return res
+	}
+
+	jobs := []*utils.Job{}
+	for i, id := range ids {
+		step := fmt.Sprintf("3.%d", i+1)
+		job, err := utils.WaitForJob(res, step, root, id, "stopped", "ok", jobTimeout, "operator")
+		if err != nil {
+			return res
+		}
+		jobs = append(jobs, job)
+	}
+
+	err = checkOrdering(res, "4", jobs)
+	if err != nil {
+		return res
+	}
+
+	utils.Pass(res)
+	return res
+}
@@ -0,0 +1,139 @@
+// SPDX-License-Identifier: Apache-2.0 OR GPL-2.0-or-later
+
+// Package spdx parses and validates the SPDX tag-value and
+// JSON documents that spdxwriter agents write to the spdx
+// volume.
+package spdx
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// Document holds the parts of an SPDX document that the tests
+// make assertions about. It is not a complete SPDX model.
+type Document struct {
+	SPDXVersion   string
+	DataLicense   string
+	SPDXID        string
+	Name          string
+	Namespace     string
+	Creators      []string
+	Packages      []*Package
+	Files         []*File
+	Relationships []*Relationship
+}
+
+// Package holds the package-level fields of an SPDX document.
+type Package struct {
+	Name                 string
+	SPDXID               string
+	DownloadLocation     string
+	LicenseConcluded     string
+	LicenseDeclared      string
+	LicenseInfoFromFiles []string
+}
+
+// File holds the file-level fields of an SPDX document.
+type File struct {
+	Name              string
+	SPDXID            string
+	LicenseConcluded  string
+	LicenseInfoInFile []string
+
+	// Checksums maps an algorithm, e.g. "SHA1", to its value.
+	Checksums map[string]string
+}
+
+// Relationship holds one SPDX relationship, e.g.
+// "SPDXRef-DOCUMENT DESCRIBES SPDXRef-Package".
+type Relationship struct {
+	RefA string
+	Type string
+	RefB string
+}
+
+// Package returns the package with the given name, or nil if
+// there is none.
+func (doc *Document) Package(name string) *Package {
+	for _, p := range doc.Packages {
+		if p.Name == name {
+			return p
+		}
+	}
+	return nil
+}
+
+// File returns the file with the given name, or nil if there
+// is none. A leading "./" is ignored on both sides, since
+// SPDX file names are conventionally written with one.
+func (doc *Document) File(name string) *File {
+	name = strings.TrimPrefix(name, "./")
+	for _, f := range doc.Files {
+		if strings.TrimPrefix(f.Name, "./") == name {
+			return f
+		}
+	}
+	return nil
+}
+
+// HasRelationship returns whether the document contains the
+// relationship "refA relType refB".
+func (doc *Document) HasRelationship(refA string, relType string, refB string) bool {
+	for _, r := range doc.Relationships {
+		if r.RefA == refA && r.Type == relType && r.RefB == refB {
+			return true
+		}
+	}
+	return false
+}
+
+// ParseFile parses the SPDX document at path. Files ending in
+// ".json" are parsed as SPDX JSON; all others are parsed as
+// tag-value.
+func ParseFile(path string) (*Document, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	var doc *Document
+	if strings.HasSuffix(path, ".json") {
+		doc, err = ParseJSON(f)
+	} else {
+		doc, err = ParseTagValue(f)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("error parsing %s: %v", path, err)
+	}
+	return doc, nil
+}
+
+// FindDocuments returns the paths of all files under dir that
+// look like SPDX documents, i.e. that end in ".spdx" or
+// ".spdx.json", in sorted order.
+func FindDocuments(dir string) ([]string, error) {
+	paths := []string{}
+	err := filepath.Walk(dir, func(p string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if !info.Mode().IsRegular() {
+			return nil
+		}
+		if strings.HasSuffix(p, ".spdx") || strings.HasSuffix(p, ".spdx.json") {
+			paths = append(paths, p)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	sort.Strings(paths)
+	return paths, nil
+}
@@ -0,0 +1,106 @@
+// SPDX-License-Identifier: Apache-2.0 OR GPL-2.0-or-later
+
+package spdx
+
+import (
+	"encoding/json"
+	"io"
+)
+
+// jsonDocument mirrors the subset of the SPDX JSON schema that
+// is converted into a Document.
+type jsonDocument struct {
+	SPDXVersion  string `json:"spdxVersion"`
+	DataLicense  string `json:"dataLicense"`
+	SPDXID       string `json:"SPDXID"`
+	Name         string `json:"name"`
+	Namespace    string `json:"documentNamespace"`
+	CreationInfo struct {
+		Creators []string `json:"creators"`
+	} `json:"creationInfo"`
+	DocumentDescribes []string `json:"documentDescribes"`
+	Packages          []struct {
+		Name                 string   `json:"name"`
+		SPDXID               string   `json:"SPDXID"`
+		DownloadLocation     string   `json:"downloadLocation"`
+		LicenseConcluded     string   `json:"licenseConcluded"`
+		LicenseDeclared      string   `json:"licenseDeclared"`
+		LicenseInfoFromFiles []string `json:"licenseInfoFromFiles"`
+	} `json:"packages"`
+	Files []struct {
+		Name               string   `json:"fileName"`
+		SPDXID             string   `json:"SPDXID"`
+		LicenseConcluded   string   `json:"licenseConcluded"`
+		LicenseInfoInFiles []string `json:"licenseInfoInFiles"`
+		Checksums          []struct {
+			Algorithm string `json:"algorithm"`
+			Value     string `json:"checksumValue"`
+		} `json:"checksums"`
+	} `json:"files"`
+	Relationships []struct {
+		RefA string `json:"spdxElementId"`
+		Type string `json:"relationshipType"`
+		RefB string `json:"relatedSpdxElement"`
+	} `json:"relationships"`
+}
+
+// ParseJSON parses an SPDX JSON document from r. Entries in
+// documentDescribes are converted into DESCRIBES relationships.
+func ParseJSON(r io.Reader) (*Document, error) {
+	var jd jsonDocument
+	err := json.NewDecoder(r).Decode(&jd)
+	if err != nil {
+		return nil, err
+	}
+
+	doc := &Document{
+		SPDXVersion: jd.SPDXVersion,
+		DataLicense: jd.DataLicense,
+		SPDXID:      jd.SPDXID,
+		Name:        jd.Name,
+		Namespace:   jd.Namespace,
+		Creators:    jd.CreationInfo.Creators,
+	}
+
+	for _, jp := range jd.Packages {
+		doc.Packages = append(doc.Packages, &Package{
+			Name:                 jp.Name,
+			SPDXID:               jp.SPDXID,
+			DownloadLocation:     jp.DownloadLocation,
+			LicenseConcluded:     jp.LicenseConcluded,
+			LicenseDeclared:      jp.LicenseDeclared,
+			LicenseInfoFromFiles: jp.LicenseInfoFromFiles,
+		})
+	}
+
+	for _, jf := range jd.Files {
+		f := &File{
+			Name:              jf.Name,
+			SPDXID:            jf.SPDXID,
+			LicenseConcluded:  jf.LicenseConcluded,
+			LicenseInfoInFile: jf.LicenseInfoInFiles,
+			Checksums:         map[string]string{},
+		}
+		for _, c := range jf.Checksums {
+			f.Checksums[c.Algorithm] = c.Value
+		}
+		doc.Files = append(doc.Files, f)
+	}
+
+	for _, described := range jd.DocumentDescribes {
+		doc.Relationships = append(doc.Relationships, &Relationship{
+			RefA: jd.SPDXID,
+			Type: "DESCRIBES",
+			RefB: described,
+		})
+	}
+	for _, jr := range jd.Relationships {
+		doc.Relationships = append(doc.Relationships, &Relationship{
+			RefA: jr.RefA,
+			Type: jr.Type,
+			RefB: jr.RefB,
+		})
+	}
+
+	return doc, nil
+}
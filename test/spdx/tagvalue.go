@@ -0,0 +1,149 @@
+// SPDX-License-Identifier: Apache-2.0 OR GPL-2.0-or-later
+
+package spdx
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// tvParser tracks which element the next tag-value pair applies
+// to while parsing a tag-value document.
+type tvParser struct {
+	doc  *Document
+	pkg  *Package
+	file *File
+}
+
+// ParseTagValue parses an SPDX tag-value document from r.
+// Tags that are not part of the Document model are ignored.
+func ParseTagValue(r io.Reader) (*Document, error) {
+	p := &tvParser{doc: &Document{}}
+
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64*1024), 16*1024*1024)
+	lineNum := 0
+	for scanner.Scan() {
+		lineNum++
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		i := strings.Index(line, ":")
+		if i < 0 {
+			return nil, fmt.Errorf("line %d: expected \"Tag: Value\", got %q", lineNum, line)
+		}
+		tag := strings.TrimSpace(line[:i])
+		value := strings.TrimSpace(line[i+1:])
+
+		// multi-line values are wrapped in <text>...</text>
+		if strings.HasPrefix(value, "<text>") {
+			startLine := lineNum
+			value = strings.TrimPrefix(value, "<text>")
+			for !strings.Contains(value, "</text>") {
+				if !scanner.Scan() {
+					return nil, fmt.Errorf("line %d: unterminated <text> value for %s", startLine, tag)
+				}
+				lineNum++
+				value += "\n" + scanner.Text()
+			}
+			value = value[:strings.Index(value, "</text>")]
+		}
+
+		err := p.apply(tag, value)
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %v", lineNum, err)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+
+	return p.doc, nil
+}
+
+// apply records one tag-value pair.
+func (p *tvParser) apply(tag string, value string) error {
+	switch tag {
+	// document creation info
+	case "SPDXVersion":
+		p.doc.SPDXVersion = value
+	case "DataLicense":
+		p.doc.DataLicense = value
+	case "DocumentName":
+		p.doc.Name = value
+	case "DocumentNamespace":
+		p.doc.Namespace = value
+	case "Creator":
+		p.doc.Creators = append(p.doc.Creators, value)
+
+	// SPDXID applies to whichever element was most recently started
+	case "SPDXID":
+		switch {
+		case p.file != nil:
+			p.file.SPDXID = value
+		case p.pkg != nil:
+			p.pkg.SPDXID = value
+		default:
+			p.doc.SPDXID = value
+		}
+
+	// packages
+	case "PackageName":
+		p.pkg = &Package{Name: value}
+		p.file = nil
+		p.doc.Packages = append(p.doc.Packages, p.pkg)
+	case "PackageDownloadLocation", "PackageLicenseConcluded", "PackageLicenseDeclared", "PackageLicenseInfoFromFiles":
+		if p.pkg == nil {
+			return fmt.Errorf("%s appears before any PackageName", tag)
+		}
+		switch tag {
+		case "PackageDownloadLocation":
+			p.pkg.DownloadLocation = value
+		case "PackageLicenseConcluded":
+			p.pkg.LicenseConcluded = value
+		case "PackageLicenseDeclared":
+			p.pkg.LicenseDeclared = value
+		case "PackageLicenseInfoFromFiles":
+			p.pkg.LicenseInfoFromFiles = append(p.pkg.LicenseInfoFromFiles, value)
+		}
+
+	// files
+	case "FileName":
+		p.file = &File{Name: value, Checksums: map[string]string{}}
+		p.doc.Files = append(p.doc.Files, p.file)
+	case "LicenseConcluded", "LicenseInfoInFile", "FileChecksum":
+		if p.file == nil {
+			return fmt.Errorf("%s appears before any FileName", tag)
+		}
+		switch tag {
+		case "LicenseConcluded":
+			p.file.LicenseConcluded = value
+		case "LicenseInfoInFile":
+			p.file.LicenseInfoInFile = append(p.file.LicenseInfoInFile, value)
+		case "FileChecksum":
+			i := strings.Index(value, ":")
+			if i < 0 {
+				return fmt.Errorf("expected \"ALGORITHM: value\" for FileChecksum, got %q", value)
+			}
+			p.file.Checksums[strings.TrimSpace(value[:i])] = strings.TrimSpace(value[i+1:])
+		}
+
+	// relationships
+	case "Relationship":
+		fields := strings.Fields(value)
+		if len(fields) != 3 {
+			return fmt.Errorf("expected \"refA TYPE refB\" for Relationship, got %q", value)
+		}
+		p.doc.Relationships = append(p.doc.Relationships, &Relationship{
+			RefA: fields[0],
+			Type: fields[1],
+			RefB: fields[2],
+		})
+	}
+
+	return nil
+}
@@ -0,0 +1,116 @@
+// SPDX-License-Identifier: Apache-2.0 OR GPL-2.0-or-later
+
+package spdx
+
+import (
+	"os"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// seededDocument is the tag-value document that the spdx volume
+// is seeded with before each test.
+const seededDocument = "../../fixtures/testdata/volumes/spdx/testrepo.spdx"
+
+func TestParseTagValueTextValues(t *testing.T) {
+	tests := []struct {
+		name  string
+		input string
+		want  string
+	}{
+		{"plain", "DocumentName: testrepo", "testrepo"},
+		{"single line text", "DocumentName: <text>testrepo</text>", "testrepo"},
+		{"multi-line text", "DocumentName: <text>first\nsecond\nthird</text>", "first\nsecond\nthird"},
+		{"text with tags inside", "DocumentName: <text>first\nFileName: not a file\n</text>", "first\nFileName: not a file\n"},
+		{"text with colons", "DocumentName: <text>a: b\nc: d</text>", "a: b\nc: d"},
+	}
+
+	for _, tt := range tests {
+		doc, err := ParseTagValue(strings.NewReader(tt.input))
+		if err != nil {
+			t.Errorf("%s: got error %v", tt.name, err)
+			continue
+		}
+		if doc.Name != tt.want {
+			t.Errorf("%s: got name %q, wanted %q", tt.name, doc.Name, tt.want)
+		}
+		if len(doc.Files) != 0 {
+			t.Errorf("%s: got %d files, wanted none", tt.name, len(doc.Files))
+		}
+	}
+}
+
+func TestParseTagValueErrors(t *testing.T) {
+	tests := []struct {
+		name  string
+		input string
+		want  string
+	}{
+		{"missing colon", "SPDXVersion SPDX-2.1", "line 1: expected"},
+		{"unterminated text", "DocumentName: testrepo\nPackageCopyrightText: <text>first\nsecond", "line 2: unterminated <text>"},
+		{"package field before package", "PackageDownloadLocation: NONE", "before any PackageName"},
+		{"file field before file", "PackageName: testrepo\nLicenseConcluded: MIT", "line 2: LicenseConcluded appears before any FileName"},
+		{"bad checksum", "FileName: ./a\nFileChecksum: abcdef", "expected \"ALGORITHM: value\""},
+		{"bad relationship", "Relationship: SPDXRef-DOCUMENT DESCRIBES", "expected \"refA TYPE refB\""},
+	}
+
+	for _, tt := range tests {
+		_, err := ParseTagValue(strings.NewReader(tt.input))
+		if err == nil {
+			t.Errorf("%s: got no error, wanted %q", tt.name, tt.want)
+			continue
+		}
+		if !strings.Contains(err.Error(), tt.want) {
+			t.Errorf("%s: got error %q, wanted it to contain %q", tt.name, err.Error(), tt.want)
+		}
+	}
+}
+
+func TestParseTagValueSeededDocument(t *testing.T) {
+	doc, err := ParseFile(seededDocument)
+	if err != nil {
+		t.Fatalf("got error %v", err)
+	}
+	if errs := Validate(doc); errs != nil {
+		t.Fatalf("got validation errors %v", errs)
+	}
+
+	if len(doc.Packages) != 1 || doc.Package("testrepo") == nil {
+		t.Errorf("got packages %v, wanted only testrepo", doc.Packages)
+	}
+	if len(doc.Files) != 3 {
+		t.Errorf("got %d files, wanted 3", len(doc.Files))
+	}
+	f := doc.File("hello.c")
+	if f == nil {
+		t.Fatalf("got no file hello.c")
+	}
+	if f.SPDXID != "SPDXRef-File-hello.c" || f.LicenseConcluded != "Apache-2.0" || f.Checksums["SHA1"] != "f84f1ca21e55c165536788752cb800211b23f688" {
+		t.Errorf("got hello.c %+v", f)
+	}
+	if !doc.HasRelationship("SPDXRef-Package-testrepo", "CONTAINS", "SPDXRef-File-README.md") {
+		t.Errorf("got relationships %v, wanted testrepo CONTAINS README.md", doc.Relationships)
+	}
+}
+
+func TestParseJSONMatchesTagValue(t *testing.T) {
+	tv, err := ParseFile(seededDocument)
+	if err != nil {
+		t.Fatalf("got error %v parsing tag-value", err)
+	}
+
+	f, err := os.Open("testdata/testrepo.spdx.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	js, err := ParseJSON(f)
+	if err != nil {
+		t.Fatalf("got error %v parsing JSON", err)
+	}
+
+	if !reflect.DeepEqual(tv, js) {
+		t.Errorf("tag-value and JSON documents differ:\ntag-value: %+v\nJSON:      %+v", tv, js)
+	}
+}
@@ -0,0 +1,108 @@
+// SPDX-License-Identifier: Apache-2.0 OR GPL-2.0-or-later
+
+package spdx
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Validate checks doc for the structural problems that an
+// spdxwriter agent is most likely to get wrong: missing
+// required fields, including each file's SHA1 checksum,
+// malformed or duplicate SPDX identifiers, and relationships
+// that refer to unknown elements. It returns one
+// error per problem found, or nil if there are none.
+func Validate(doc *Document) []error {
+	errs := []error{}
+
+	if !strings.HasPrefix(doc.SPDXVersion, "SPDX-") {
+		errs = append(errs, fmt.Errorf("invalid SPDXVersion %q", doc.SPDXVersion))
+	}
+	if doc.DataLicense != "CC0-1.0" {
+		errs = append(errs, fmt.Errorf("expected DataLicense CC0-1.0, got %q", doc.DataLicense))
+	}
+	if doc.SPDXID != "SPDXRef-DOCUMENT" {
+		errs = append(errs, fmt.Errorf("expected document SPDXID SPDXRef-DOCUMENT, got %q", doc.SPDXID))
+	}
+	if doc.Name == "" {
+		errs = append(errs, fmt.Errorf("missing document name"))
+	}
+	if doc.Namespace == "" {
+		errs = append(errs, fmt.Errorf("missing document namespace"))
+	}
+	if len(doc.Creators) == 0 {
+		errs = append(errs, fmt.Errorf("missing document creator"))
+	}
+
+	ids := map[string]bool{doc.SPDXID: true}
+	checkID := func(kind string, name string, id string) {
+		if !strings.HasPrefix(id, "SPDXRef-") {
+			errs = append(errs, fmt.Errorf("%s %q has invalid SPDXID %q", kind, name, id))
+			return
+		}
+		if ids[id] {
+			errs = append(errs, fmt.Errorf("%s %q has duplicate SPDXID %q", kind, name, id))
+			return
+		}
+		ids[id] = true
+	}
+
+	for _, p := range doc.Packages {
+		if p.Name == "" {
+			errs = append(errs, fmt.Errorf("package with SPDXID %q is missing its name", p.SPDXID))
+		}
+		checkID("package", p.Name, p.SPDXID)
+		if p.DownloadLocation == "" {
+			errs = append(errs, fmt.Errorf("package %q is missing its download location", p.Name))
+		}
+	}
+
+	for _, f := range doc.Files {
+		if f.Name == "" {
+			errs = append(errs, fmt.Errorf("file with SPDXID %q is missing its name", f.SPDXID))
+		}
+		checkID("file", f.Name, f.SPDXID)
+		if f.LicenseConcluded == "" {
+			errs = append(errs, fmt.Errorf("file %q is missing its concluded license", f.Name))
+		}
+		if sum, ok := f.Checksums["SHA1"]; !ok {
+			errs = append(errs, fmt.Errorf("file %q is missing its SHA1 checksum", f.Name))
+		} else if !isHex(sum, 40) {
+			errs = append(errs, fmt.Errorf("file %q has invalid SHA1 checksum %q", f.Name, sum))
+		}
+	}
+
+	knownRef := func(ref string) bool {
+		return ids[ref] || ref == "NONE" || ref == "NOASSERTION" || strings.HasPrefix(ref, "DocumentRef-")
+	}
+	for _, r := range doc.Relationships {
+		if !knownRef(r.RefA) {
+			errs = append(errs, fmt.Errorf("relationship %s %s %s refers to unknown element %q", r.RefA, r.Type, r.RefB, r.RefA))
+		}
+		if !knownRef(r.RefB) {
+			errs = append(errs, fmt.Errorf("relationship %s %s %s refers to unknown element %q", r.RefA, r.Type, r.RefB, r.RefB))
+		}
+		if r.Type == "" || strings.ToUpper(r.Type) != r.Type {
+			errs = append(errs, fmt.Errorf("relationship %s %s %s has invalid type %q", r.RefA, r.Type, r.RefB, r.Type))
+		}
+	}
+
+	if len(errs) == 0 {
+		return nil
+	}
+	return errs
+}
+
+// isHex returns whether s is n lowercase hex digits.
+func isHex(s string, n int) bool {
+	if len(s) != n {
+		return false
+	}
+	for _, c := range s {
+		if (c < '0' || c > '9') && (c < 'a' || c > 'f') {
+			return false
+		}
+	}
+	return true
+}
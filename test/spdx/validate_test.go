@@ -0,0 +1,71 @@
+// SPDX-License-Identifier: Apache-2.0 OR GPL-2.0-or-later
+
+package spdx
+
+import (
+	"strings"
+	"testing"
+)
+
+// validDocument returns a minimal document that passes Validate.
+func validDocument() *Document {
+	return &Document{
+		SPDXVersion: "SPDX-2.1",
+		DataLicense: "CC0-1.0",
+		SPDXID:      "SPDXRef-DOCUMENT",
+		Name:        "testrepo",
+		Namespace:   "https://example.com/testrepo",
+		Creators:    []string{"Tool: test"},
+		Packages: []*Package{
+			{Name: "testrepo", SPDXID: "SPDXRef-Package", DownloadLocation: "NOASSERTION"},
+		},
+		Files: []*File{
+			{Name: "./a.c", SPDXID: "SPDXRef-File-a", LicenseConcluded: "MIT", Checksums: map[string]string{"SHA1": "f84f1ca21e55c165536788752cb800211b23f688"}},
+		},
+		Relationships: []*Relationship{
+			{RefA: "SPDXRef-DOCUMENT", Type: "DESCRIBES", RefB: "SPDXRef-Package"},
+			{RefA: "SPDXRef-Package", Type: "CONTAINS", RefB: "SPDXRef-File-a"},
+		},
+	}
+}
+
+func TestValidate(t *testing.T) {
+	tests := []struct {
+		name   string
+		modify func(doc *Document)
+		want   string
+	}{
+		{"valid", func(doc *Document) {}, ""},
+		{"bad version", func(doc *Document) { doc.SPDXVersion = "2.1" }, "invalid SPDXVersion"},
+		{"bad data license", func(doc *Document) { doc.DataLicense = "MIT" }, "expected DataLicense CC0-1.0"},
+		{"missing creator", func(doc *Document) { doc.Creators = nil }, "missing document creator"},
+		{"duplicate SPDXID", func(doc *Document) { doc.Files[0].SPDXID = "SPDXRef-Package" }, "duplicate SPDXID"},
+		{"invalid SPDXID", func(doc *Document) { doc.Packages[0].SPDXID = "Package" }, "invalid SPDXID"},
+		{"missing SHA1", func(doc *Document) { doc.Files[0].Checksums = map[string]string{"MD5": "abc"} }, "missing its SHA1 checksum"},
+		{"short SHA1", func(doc *Document) { doc.Files[0].Checksums["SHA1"] = "f84f1ca2" }, "invalid SHA1 checksum"},
+		{"uppercase SHA1", func(doc *Document) { doc.Files[0].Checksums["SHA1"] = strings.ToUpper(doc.Files[0].Checksums["SHA1"]) }, "invalid SHA1 checksum"},
+		{"unknown element", func(doc *Document) { doc.Relationships[1].RefB = "SPDXRef-File-b" }, "refers to unknown element \"SPDXRef-File-b\""},
+		{"lowercase type", func(doc *Document) { doc.Relationships[0].Type = "describes" }, "invalid type"},
+	}
+
+	for _, tt := range tests {
+		doc := validDocument()
+		tt.modify(doc)
+		errs := Validate(doc)
+		if tt.want == "" {
+			if errs != nil {
+				t.Errorf("%s: got errors %v, wanted none", tt.name, errs)
+			}
+			continue
+		}
+		found := false
+		for _, err := range errs {
+			if strings.Contains(err.Error(), tt.want) {
+				found = true
+			}
+		}
+		if !found {
+			t.Errorf("%s: got errors %v, wanted one containing %q", tt.name, errs, tt.want)
+		}
+	}
+}
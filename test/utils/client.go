@@ -0,0 +1,15 @@
+// SPDX-License-Identifier: Apache-2.0 OR GPL-2.0-or-later
+
+package utils
+
+import "net/http"
+
+// Transport is used for every HTTP call made by the helpers in
+// this package, and by anything else that gets its client from
+// Client. It can be replaced, e.g. to record traffic.
+var Transport http.RoundTripper = http.DefaultTransport
+
+// Client returns a new HTTP client that uses Transport.
+func Client() *http.Client {
+	return &http.Client{Transport: Transport}
+}
@@ -0,0 +1,114 @@
+// SPDX-License-Identifier: Apache-2.0 OR GPL-2.0-or-later
+
+package utils
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/swinslow/peridot-jobrunner-testing/internal/testresult"
+)
+
+// DryRun, if true, means that the helpers in this package do
+// not make HTTP calls or check files. Instead, they record the
+// step that they would have performed, and act as though it
+// succeeded, with the TestResult's Got value set to its Wanted
+// value. It is set by DryRunTest.
+var DryRun = false
+
+// PlannedStep is one step that a test would perform, as found
+// by a dry run.
+type PlannedStep struct {
+	Step        string
+	Description string
+}
+
+// errDryRun is returned for HTTP calls that are made during a
+// dry run without going through the helpers in this package.
+var errDryRun = errors.New("no HTTP calls are made during a dry run")
+
+// dryRunTransport refuses every request.
+type dryRunTransport struct{}
+
+func (dryRunTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	return nil, errDryRun
+}
+
+// dryRunFirstID is the first placeholder ID that ParseID returns
+// during a dry run; later calls count up from it. It is the ID
+// that the API gives the first job created after the fixtures
+// are set up, so that tests which go on to use the IDs of the
+// jobs that they create plan the same steps as in a real run.
+const dryRunFirstID = 5
+
+var (
+	dryRunRoot   string
+	dryRunNextID uint32
+	planned      []PlannedStep
+)
+
+// DryRunTest runs t without making any HTTP calls, and returns
+// its result along with the steps that it would have performed.
+// The result always identifies the test. A test that needs real
+// responses to decide what to do next may stop early, in which
+// case the result describes where it stopped.
+func DryRunTest(t testresult.TestFunc, root string) (*testresult.TestResult, []PlannedStep) {
+	prevTransport := Transport
+	Transport = dryRunTransport{}
+	DryRun = true
+	dryRunRoot = root
+	dryRunNextID = dryRunFirstID
+	planned = nil
+	defer func() {
+		Transport = prevTransport
+		DryRun = false
+	}()
+
+	res := t(root)
+	return res, planned
+}
+
+// plan records a step during a dry run.
+func plan(step string, format string, args ...interface{}) {
+	planned = append(planned, PlannedStep{Step: step, Description: fmt.Sprintf(format, args...)})
+}
+
+// replan replaces the step that was last recorded during a dry
+// run, e.g. to describe a repeated HTTP call as a whole.
+func replan(step string, format string, args ...interface{}) {
+	if n := len(planned); n > 0 {
+		planned = planned[:n-1]
+	}
+	plan(step, format, args...)
+}
+
+// planRequest records an HTTP call during a dry run, and returns
+// the values that send would return for it.
+func planRequest(step string, method string, url string, bodystr string, ghUsername string) (int, []byte, error) {
+	desc := fmt.Sprintf("%s %s as %s", method, strings.TrimPrefix(url, dryRunRoot), ghUsername)
+	if bodystr != "" {
+		desc += " with body " + strings.Join(strings.Fields(bodystr), " ")
+	}
+	plan(step, "%s", desc)
+	return 0, nil, nil
+}
+
+// planExpectation adds the expected status code to the HTTP
+// call that was last recorded during a dry run, and acts as
+// though the wanted response was received.
+func planExpectation(res *testresult.TestResult, code int) {
+	if n := len(planned); n > 0 {
+		planned[n-1].Description += fmt.Sprintf(", expecting HTTP %d", code)
+	}
+	res.Got = []byte(res.Wanted)
+}
+
+// dryRunJob returns a job that has reached the given status and
+// health, for helpers that would otherwise parse a response.
+func dryRunJob(id uint32, status string, health string) *Job {
+	now := time.Now()
+	return &Job{ID: id, Status: status, Health: health, StartedAt: now, FinishedAt: now}
+}
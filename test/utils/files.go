@@ -0,0 +1,79 @@
+// SPDX-License-Identifier: Apache-2.0 OR GPL-2.0-or-later
+
+package utils
+
+import (
+	"fmt"
+	"path/filepath"
+	"strings"
+
+	"github.com/swinslow/peridot-jobrunner-testing/internal/fstree"
+	"github.com/swinslow/peridot-jobrunner-testing/internal/testresult"
+)
+
+// CodeDir and SpdxDir are the directories where the code and
+// spdx volumes that are shared with the agents are mounted.
+// SeedDir holds the code and spdx fixture trees that they are
+// seeded from before each test.
+var (
+	CodeDir = "/code"
+	SpdxDir = "/spdx"
+	SeedDir = "fixtures/testdata/volumes"
+)
+
+// Replay, if true, means that the responses to HTTP calls come
+// from a recording, so there are no volumes to check. The helpers
+// that check files then skip their step, and note in the
+// TestResult's Diagnostics that they did so. It is set by the
+// run subcommand's -replay flag.
+var Replay = false
+
+// skipFiles returns whether a step that checks files, described
+// by format and args, should be skipped: during a dry run it is
+// planned instead, and during a replay it is noted in res.
+func skipFiles(res *testresult.TestResult, step string, format string, args ...interface{}) bool {
+	switch {
+	case DryRun:
+		plan(step, format, args...)
+		return true
+	case Replay:
+		res.Diagnostics = append(res.Diagnostics, fmt.Sprintf("step %s skipped in replay: %s", step, fmt.Sprintf(format, args...)))
+		return true
+	}
+	return false
+}
+
+// CodePath returns the path to rel within the code volume.
+func CodePath(rel string) string {
+	return filepath.Join(CodeDir, filepath.FromSlash(rel))
+}
+
+// SpdxPath returns the path to rel within the spdx volume.
+func SpdxPath(rel string) string {
+	return filepath.Join(SpdxDir, filepath.FromSlash(rel))
+}
+
+// CheckTree checks that the files under dir exactly match the
+// files under the golden directory, which is typically one of
+// the fixture trees under SeedDir. On failure, it fills in the
+// failure code in the TestResult, with one entry per differing
+// file, and returns an error.
+func CheckTree(res *testresult.TestResult, step string, dir string, golden string) error {
+	if skipFiles(res, step, "check that %s matches %s", dir, golden) {
+		return nil
+	}
+
+	diffs, err := fstree.Diff(dir, golden)
+	if err != nil {
+		FailTest(res, step, err)
+		return err
+	}
+
+	if len(diffs) > 0 {
+		err = fmt.Errorf("%s did not match %s: %s", dir, golden, strings.Join(diffs, ", "))
+		FailTest(res, step, err)
+		return err
+	}
+
+	return nil
+}
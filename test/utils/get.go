@@ -3,8 +3,6 @@
 package utils
 
 import (
-	"fmt"
-	"io/ioutil"
 	"net/http"
 
 	"github.com/swinslow/peridot-jobrunner-testing/internal/testresult"
@@ -17,16 +15,8 @@ import (
 // and handles closing the body. On failure, it fills in the
 // failure code in the TestResult and returns an error.
 func GetContent(res *testresult.TestResult, step string, url string, code int, ghUsername string) error {
-	client := &http.Client{}
-	req, err := http.NewRequest("GET", url, nil)
-	if err != nil {
-		FailTest(res, step, err)
-		return err
-	}
-	AddAuthHeader(res, step, req, ghUsername)
-	resp, err := client.Do(req)
-
-	return helperGetContent(res, resp, step, code)
+	gotCode, b, err := send(res, step, Client(), "GET", url, "", ghUsername)
+	return checkSent(res, step, gotCode, b, err, code)
 }
 
 // GetContentNoFollow makes an HTTP GET call to the indicated
@@ -34,46 +24,11 @@ func GetContent(res *testresult.TestResult, step string, url string, code int, g
 // identically to GetContent.
 func GetContentNoFollow(res *testresult.TestResult, step string, url string, code int, ghUsername string) error {
 	client := &http.Client{
+		Transport: Transport,
 		CheckRedirect: func(req *http.Request, via []*http.Request) error {
 			return http.ErrUseLastResponse
 		},
 	}
-	req, err := http.NewRequest("GET", url, nil)
-	if err != nil {
-		FailTest(res, step, err)
-		return err
-	}
-	AddAuthHeader(res, step, req, ghUsername)
-	resp, err := client.Do(req)
-	if err != nil {
-		FailTest(res, step, err)
-		return err
-	}
-
-	return helperGetContent(res, resp, step, code)
-}
-
-// helperGetContent does the rest of the GetContent or
-// GetContentNoFollow activities, after the decision is
-// made on whether to follow any redirects.
-func helperGetContent(res *testresult.TestResult, resp *http.Response, step string, code int) error {
-	// parse content body
-	b, err := ioutil.ReadAll(resp.Body)
-	resp.Body.Close()
-	if err != nil {
-		FailTest(res, step, err)
-		return err
-	}
-
-	// record in testresult
-	res.Got = b
-
-	// check expected status code
-	if resp.StatusCode != code {
-		err = fmt.Errorf("expected HTTP status code %d, got %d", code, resp.StatusCode)
-		FailTest(res, step, err)
-		return err
-	}
-
-	return nil
+	gotCode, b, err := send(res, step, client, "GET", url, "", ghUsername)
+	return checkSent(res, step, gotCode, b, err, code)
 }
@@ -0,0 +1,148 @@
+// SPDX-License-Identifier: Apache-2.0 OR GPL-2.0-or-later
+
+package utils
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/swinslow/peridot-jobrunner-testing/internal/fstree"
+	"github.com/swinslow/peridot-jobrunner-testing/internal/testresult"
+)
+
+// GoldenDir is the directory containing the golden files of
+// expected responses.
+var GoldenDir = "testdata/golden"
+
+// UpdateGolden, if true, means that CheckGolden writes each
+// response to its golden file instead of comparing against it.
+var UpdateGolden = false
+
+// GoldenPath returns the path of the golden file for the given
+// step of the test.
+func GoldenPath(res *testresult.TestResult, step string) string {
+	return filepath.Join(GoldenDir, fstree.SafeName(res.Suite), fstree.SafeName(res.Element), fstree.SafeName(res.ID), fstree.SafeName(step)+".json")
+}
+
+// CheckGolden compares the TestResult's Got value against the
+// golden file for the given step, after setting the Wanted value
+// from it. If UpdateGolden is true, it instead writes the Got
+// value to the golden file, indented if it is JSON. On failure,
+// it fills in the failure code in the TestResult and returns an
+// error.
+func CheckGolden(res *testresult.TestResult, step string) error {
+	return CheckGoldenExcept(res, step)
+}
+
+// CheckGoldenExcept acts like CheckGolden, except that the JSON
+// fields at the given dot-separated paths, e.g. "job.is_ready",
+// may have any value: the Wanted value takes them from the Got
+// value. It is meant for responses where some fields depend on
+// e.g. which of several concurrent requests won.
+func CheckGoldenExcept(res *testresult.TestResult, step string, ignored ...string) error {
+	path := GoldenPath(res, step)
+
+	if DryRun {
+		plan(step, "compare response with golden file %s", path)
+		return nil
+	}
+
+	if UpdateGolden {
+		out := res.Got
+		var buf bytes.Buffer
+		if json.Indent(&buf, res.Got, "", "  ") == nil {
+			buf.WriteString("\n")
+			out = buf.Bytes()
+		}
+		err := os.MkdirAll(filepath.Dir(path), 0755)
+		if err == nil {
+			err = ioutil.WriteFile(path, out, 0644)
+		}
+		if err != nil {
+			FailTest(res, step, fmt.Errorf("could not update golden file: %v", err))
+			return err
+		}
+		res.Wanted = string(res.Got)
+		return nil
+	}
+
+	want, err := ioutil.ReadFile(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			err = fmt.Errorf("no golden file at %s; run with -update to create it", path)
+		}
+		FailTest(res, step, err)
+		return err
+	}
+	if len(ignored) > 0 {
+		want, err = copyFields(want, res.Got, ignored)
+		if err != nil {
+			err = fmt.Errorf("could not compare with golden file %s: %v", path, err)
+			FailTest(res, step, err)
+			return err
+		}
+	}
+	res.Wanted = string(want)
+
+	if len(bytes.TrimSpace(want)) == 0 {
+		if len(res.Got) != 0 {
+			err = fmt.Errorf("expected empty response, see golden file %s", path)
+			FailTest(res, step, err)
+			return err
+		}
+		return nil
+	}
+
+	if !IsMatch(res) {
+		err = fmt.Errorf("response does not match golden file %s", path)
+		FailTest(res, step, err)
+		return err
+	}
+
+	return nil
+}
+
+// copyFields returns the JSON document want, with the value at
+// each of the given dot-separated paths replaced by the value at
+// the same path in got. Paths that are missing from got are left
+// as they are, so that the comparison reports them.
+func copyFields(want []byte, got []byte, paths []string) ([]byte, error) {
+	var w, g interface{}
+	err := json.Unmarshal(want, &w)
+	if err != nil {
+		return nil, err
+	}
+	if json.Unmarshal(got, &g) != nil {
+		return want, nil
+	}
+
+	for _, p := range paths {
+		keys := strings.Split(p, ".")
+		wobj, gobj := w, g
+		for _, k := range keys[:len(keys)-1] {
+			wm, _ := wobj.(map[string]interface{})
+			gm, _ := gobj.(map[string]interface{})
+			if wm == nil || gm == nil {
+				wobj = nil
+				break
+			}
+			wobj, gobj = wm[k], gm[k]
+		}
+		wm, _ := wobj.(map[string]interface{})
+		gm, _ := gobj.(map[string]interface{})
+		last := keys[len(keys)-1]
+		if wm == nil || gm == nil {
+			continue
+		}
+		if v, ok := gm[last]; ok {
+			wm[last] = v
+		}
+	}
+
+	return json.Marshal(w)
+}
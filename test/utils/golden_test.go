@@ -0,0 +1,175 @@
+// SPDX-License-Identifier: Apache-2.0 OR GPL-2.0-or-later
+
+package utils
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/swinslow/peridot-jobrunner-testing/internal/testresult"
+)
+
+func TestCopyFields(t *testing.T) {
+	tests := []struct {
+		name  string
+		want  string
+		got   string
+		paths []string
+		out   string
+	}{
+		{
+			name:  "top level",
+			want:  `{"a": 1, "b": 2}`,
+			got:   `{"a": 3, "b": 4}`,
+			paths: []string{"a"},
+			out:   `{"a": 3, "b": 2}`,
+		},
+		{
+			name:  "nested",
+			want:  `{"job": {"id": 4, "is_ready": false}}`,
+			got:   `{"job": {"id": 5, "is_ready": true}}`,
+			paths: []string{"job.is_ready"},
+			out:   `{"job": {"id": 4, "is_ready": true}}`,
+		},
+		{
+			name:  "several paths",
+			want:  `{"job": {"id": 4, "is_ready": false, "status": "startup"}}`,
+			got:   `{"job": {"id": 4, "is_ready": true, "status": "running"}}`,
+			paths: []string{"job.is_ready", "job.status"},
+			out:   `{"job": {"id": 4, "is_ready": true, "status": "running"}}`,
+		},
+		{
+			name:  "object value",
+			want:  `{"job": {"config": {}}}`,
+			got:   `{"job": {"config": {"kv": {"a": "b"}}}}`,
+			paths: []string{"job.config"},
+			out:   `{"job": {"config": {"kv": {"a": "b"}}}}`,
+		},
+		{
+			name:  "missing from got",
+			want:  `{"job": {"is_ready": false}}`,
+			got:   `{"job": {}}`,
+			paths: []string{"job.is_ready"},
+			out:   `{"job": {"is_ready": false}}`,
+		},
+		{
+			name:  "missing parent in got",
+			want:  `{"job": {"is_ready": false}}`,
+			got:   `{"error": "not found"}`,
+			paths: []string{"job.is_ready"},
+			out:   `{"job": {"is_ready": false}}`,
+		},
+		{
+			name:  "missing from want",
+			want:  `{"job": {}}`,
+			got:   `{"job": {"is_ready": true}}`,
+			paths: []string{"job.is_ready"},
+			out:   `{"job": {"is_ready": true}}`,
+		},
+		{
+			name:  "through a non-object",
+			want:  `{"job": [1, 2]}`,
+			got:   `{"job": [3, 4]}`,
+			paths: []string{"job.is_ready"},
+			out:   `{"job": [1, 2]}`,
+		},
+		{
+			name:  "got is not JSON",
+			want:  `{"a": 1}`,
+			got:   `not found`,
+			paths: []string{"a"},
+			out:   `{"a": 1}`,
+		},
+	}
+
+	for _, tc := range tests {
+		out, err := copyFields([]byte(tc.want), []byte(tc.got), tc.paths)
+		if err != nil {
+			t.Errorf("%s: got error %v", tc.name, err)
+			continue
+		}
+		var o, wanted interface{}
+		if err := json.Unmarshal(out, &o); err != nil {
+			t.Errorf("%s: got invalid JSON %q: %v", tc.name, out, err)
+			continue
+		}
+		json.Unmarshal([]byte(tc.out), &wanted)
+		if !reflect.DeepEqual(o, wanted) {
+			t.Errorf("%s: got %s, wanted %s", tc.name, out, tc.out)
+		}
+	}
+}
+
+func TestCopyFieldsInvalidWant(t *testing.T) {
+	_, err := copyFields([]byte(`{"a":`), []byte(`{"a": 1}`), []string{"a"})
+	if err == nil {
+		t.Errorf("got no error, wanted one for an invalid golden file")
+	}
+}
+
+func TestUpdateThenCheckGolden(t *testing.T) {
+	dir, err := ioutil.TempDir("", "golden-test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	prevDir, prevUpdate := GoldenDir, UpdateGolden
+	defer func() {
+		GoldenDir, UpdateGolden = prevDir, prevUpdate
+	}()
+	GoldenDir = dir
+
+	newResult := func(got string) *testresult.TestResult {
+		return &testresult.TestResult{Suite: "endpoints", Element: "jobs/{id}", ID: "GET (viewer)", Got: []byte(got)}
+	}
+	got := `{"job":{"id":4,"is_ready":false}}`
+
+	// updating writes the response, indented, keyed by the
+	// test's name and step
+	UpdateGolden = true
+	res := newResult(got)
+	err = CheckGolden(res, "2")
+	if err != nil {
+		t.Fatalf("update: got error %v", err)
+	}
+	path := filepath.Join(dir, "endpoints", "jobs_id", "GET_viewer", "2.json")
+	b, err := ioutil.ReadFile(path)
+	if err != nil {
+		t.Fatalf("update: golden file not written: %v", err)
+	}
+	if want := "{\n  \"job\": {\n    \"id\": 4,\n    \"is_ready\": false\n  }\n}\n"; string(b) != want {
+		t.Errorf("update: wrote %q, wanted %q", b, want)
+	}
+
+	// and a normal run then compares against it
+	UpdateGolden = false
+	tests := []struct {
+		got     string
+		ignored []string
+		success bool
+	}{
+		{got, nil, true},
+		{`{"job": {"is_ready": false, "id": 4}}`, nil, true},
+		{`{"job":{"id":4,"is_ready":true}}`, nil, false},
+		{`{"job":{"id":4,"is_ready":true}}`, []string{"job.is_ready"}, true},
+		{`{"job":{"id":5,"is_ready":true}}`, []string{"job.is_ready"}, false},
+	}
+	for _, tc := range tests {
+		res := newResult(tc.got)
+		res.Success = true
+		err := CheckGoldenExcept(res, "2", tc.ignored...)
+		if (err == nil) != tc.success || res.Success != tc.success {
+			t.Errorf("%s ignoring %v: got error %v, success %t, wanted success %t", tc.got, tc.ignored, err, res.Success, tc.success)
+		}
+	}
+
+	// a step with no golden file fails
+	res = newResult(got)
+	if err := CheckGolden(res, "3"); err == nil {
+		t.Errorf("got no error for a missing golden file")
+	}
+}
@@ -0,0 +1,229 @@
+// SPDX-License-Identifier: Apache-2.0 OR GPL-2.0-or-later
+
+package utils
+
+import (
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/swinslow/peridot-jobrunner-testing/internal/testresult"
+)
+
+// Job mirrors the JSON representation of a job that is
+// returned by the API.
+type Job struct {
+	ID          uint32          `json:"id"`
+	RepoPullID  uint32          `json:"repopull_id"`
+	AgentID     uint32          `json:"agent_id"`
+	PriorJobIDs []uint32        `json:"priorjob_ids"`
+	StartedAt   time.Time       `json:"started_at"`
+	FinishedAt  time.Time       `json:"finished_at"`
+	Status      string          `json:"status"`
+	Health      string          `json:"health"`
+	IsReady     bool            `json:"is_ready"`
+	Config      json.RawMessage `json:"config"`
+}
+
+// GetJob makes an HTTP GET call for the job with the given ID,
+// and parses the response. On failure, it fills in the failure
+// code in the TestResult and returns an error.
+func GetJob(res *testresult.TestResult, step string, root string, id uint32, ghUsername string) (*Job, error) {
+	url := fmt.Sprintf("%s/jobs/%d", root, id)
+	err := GetContent(res, step, url, 200, ghUsername)
+	if err != nil {
+		return nil, err
+	}
+
+	if DryRun {
+		return dryRunJob(id, "", ""), nil
+	}
+
+	var js struct {
+		Job *Job `json:"job"`
+	}
+	err = json.Unmarshal(res.Got, &js)
+	if err == nil && js.Job == nil {
+		err = fmt.Errorf("no job in response")
+	}
+	if err != nil {
+		FailTest(res, step, err)
+		return nil, err
+	}
+
+	return js.Job, nil
+}
+
+// ListJobs makes an HTTP GET call for all jobs in the given
+// repo pull, and parses the response. On failure, it fills in
+// the failure code in the TestResult and returns an error.
+func ListJobs(res *testresult.TestResult, step string, root string, repoPullID uint32, ghUsername string) ([]*Job, error) {
+	url := fmt.Sprintf("%s/repopulls/%d/jobs", root, repoPullID)
+	err := GetContent(res, step, url, 200, ghUsername)
+	if err != nil {
+		return nil, err
+	}
+
+	if DryRun {
+		return []*Job{}, nil
+	}
+
+	var js struct {
+		Jobs []*Job `json:"jobs"`
+	}
+	err = json.Unmarshal(res.Got, &js)
+	if err != nil {
+		FailTest(res, step, err)
+		return nil, err
+	}
+
+	return js.Jobs, nil
+}
+
+// WaitForJob polls the job with the given ID, backing off
+// between calls, until its status and health reach the wanted
+// values or until timeout elapses. Each change in status or
+// health that it observes is recorded in the TestResult's
+// Transitions. If the job stops without reaching the wanted
+// values, it gives up without waiting for the timeout.
+// On success, it returns the final job. On failure, it fills
+// in the failure code in the TestResult and returns an error.
+func WaitForJob(res *testresult.TestResult, step string, root string, id uint32, status string, health string, timeout time.Duration, ghUsername string) (*Job, error) {
+	deadline := time.Now().Add(timeout)
+	delay := 100 * time.Millisecond
+	maxDelay := 2 * time.Second
+	var lastStatus, lastHealth string
+
+	for {
+		job, err := GetJob(res, step, root, id, ghUsername)
+		if err != nil {
+			return nil, err
+		}
+		if DryRun {
+			replan(step, "poll job %d as %s until it reaches status %s and health %s, for up to %s", id, ghUsername, status, health, timeout)
+			return dryRunJob(id, status, health), nil
+		}
+
+		if job.Status != lastStatus || job.Health != lastHealth {
+			res.Transitions = append(res.Transitions, testresult.JobTransition{
+				JobID:  id,
+				At:     time.Now(),
+				Status: job.Status,
+				Health: job.Health,
+			})
+			lastStatus = job.Status
+			lastHealth = job.Health
+		}
+
+		if job.Status == status && job.Health == health {
+			return job, nil
+		}
+
+		if job.Status == "stopped" {
+			err = fmt.Errorf("job %d stopped with health %s, wanted status %s and health %s", id, job.Health, status, health)
+			FailTest(res, step, err)
+			return job, err
+		}
+
+		if time.Now().Add(delay).After(deadline) {
+			err = fmt.Errorf("timed out after %s waiting for job %d to reach status %s and health %s; last saw status %s and health %s", timeout, id, status, health, job.Status, job.Health)
+			FailTest(res, step, err)
+			return job, err
+		}
+
+		time.Sleep(delay)
+		delay *= 2
+		if delay > maxDelay {
+			delay = maxDelay
+		}
+	}
+}
+
+// ParseID parses a response of the form {"id": N}, as returned
+// by the API when creating an object, from the TestResult's Got
+// value. On failure, it fills in the failure code in the
+// TestResult and returns an error.
+func ParseID(res *testresult.TestResult, step string) (uint32, error) {
+	if DryRun {
+		id := dryRunNextID
+		dryRunNextID++
+		return id, nil
+	}
+
+	var js struct {
+		ID *uint32 `json:"id"`
+	}
+	err := json.Unmarshal(res.Got, &js)
+	if err == nil && js.ID == nil {
+		err = fmt.Errorf("no id in response")
+	}
+	if err != nil {
+		FailTest(res, step, err)
+		return 0, err
+	}
+
+	return *js.ID, nil
+}
+
+// CreateJob makes an HTTP POST call to create a job in the
+// given repo pull, and returns the new job's ID. The config
+// string is sent as-is and must be a JSON object. On failure,
+// it fills in the failure code in the TestResult and returns
+// an error.
+func CreateJob(res *testresult.TestResult, step string, root string, repoPullID uint32, agentID uint32, priorJobIDs []uint32, config string, isReady bool, ghUsername string) (uint32, error) {
+	url := fmt.Sprintf("%s/repopulls/%d/jobs", root, repoPullID)
+	if priorJobIDs == nil {
+		priorJobIDs = []uint32{}
+	}
+	priors, err := json.Marshal(priorJobIDs)
+	if err != nil {
+		FailTest(res, step, err)
+		return 0, err
+	}
+
+	body := fmt.Sprintf(`{"agent_id":%d, "is_ready":%t, "priorjob_ids":%s, "config":%s}`, agentID, isReady, priors, config)
+	err = Post(res, step, url, body, 201, ghUsername)
+	if err != nil {
+		return 0, err
+	}
+
+	return ParseID(res, step)
+}
+
+// SetJobReady makes an HTTP PUT call to update the is_ready
+// flag of the job with the given ID. On failure, it fills in
+// the failure code in the TestResult and returns an error.
+func SetJobReady(res *testresult.TestResult, step string, root string, id uint32, isReady bool, ghUsername string) error {
+	url := fmt.Sprintf("%s/jobs/%d", root, id)
+	body := fmt.Sprintf(`{"is_ready": %t}`, isReady)
+	return Put(res, step, url, body, 204, ghUsername)
+}
+
+// DumpJobs adds the jobrunner's view of every job in the given
+// repo pulls to the TestResult's Diagnostics, one line per job.
+// It is meant for AfterEach hooks, so it does not change the
+// TestResult's success or failure, even if the jobs cannot be
+// listed; in that case it returns an error.
+func DumpJobs(res *testresult.TestResult, root string, repoPullIDs ...uint32) error {
+	for _, rpID := range repoPullIDs {
+		scratch := &testresult.TestResult{}
+		jobs, err := ListJobs(scratch, "", root, rpID, "viewer")
+		if err != nil {
+			return fmt.Errorf("couldn't list jobs for repo pull %d: %v", rpID, err)
+		}
+		for _, j := range jobs {
+			res.Diagnostics = append(res.Diagnostics, fmt.Sprintf("repo pull %d job %d: agent %d, priors %v, ready %t, %s / %s, started %s, finished %s",
+				rpID, j.ID, j.AgentID, j.PriorJobIDs, j.IsReady, j.Status, j.Health, jobTime(j.StartedAt), jobTime(j.FinishedAt)))
+		}
+	}
+
+	return nil
+}
+
+// jobTime formats a job's start or finish time for DumpJobs.
+func jobTime(t time.Time) string {
+	if t.IsZero() {
+		return "-"
+	}
+	return t.Format("15:04:05.000")
+}
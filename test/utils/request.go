@@ -0,0 +1,95 @@
+// SPDX-License-Identifier: Apache-2.0 OR GPL-2.0-or-later
+
+package utils
+
+import (
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/swinslow/peridot-jobrunner-testing/internal/testresult"
+)
+
+// send makes an HTTP call with the given client, and returns
+// the response's status code and body. If res is not nil, the
+// call is recorded in its Exchanges. It does not check the
+// status code or fill in any failure fields.
+func send(res *testresult.TestResult, step string, client *http.Client, method string, url string, bodystr string, ghUsername string) (int, []byte, error) {
+	if DryRun {
+		return planRequest(step, method, url, bodystr, ghUsername)
+	}
+
+	var body io.Reader
+	if method != "GET" {
+		body = strings.NewReader(bodystr)
+	}
+	req, err := http.NewRequest(method, url, body)
+	if err != nil {
+		return 0, nil, err
+	}
+	AddAuthHeader(res, step, req, ghUsername)
+
+	ex := testresult.Exchange{
+		Step:        step,
+		Method:      method,
+		URL:         url,
+		User:        ghUsername,
+		RequestBody: bodystr,
+		Start:       time.Now(),
+	}
+
+	resp, err := client.Do(req)
+	if err != nil {
+		recordExchange(res, ex)
+		return 0, nil, err
+	}
+	defer resp.Body.Close()
+
+	// parse content body
+	b, err := ioutil.ReadAll(resp.Body)
+	ex.Duration = time.Since(ex.Start)
+	ex.StatusCode = resp.StatusCode
+	ex.ResponseBody = b
+	recordExchange(res, ex)
+
+	return resp.StatusCode, b, err
+}
+
+// recordExchange appends ex to the TestResult's Exchanges, if
+// there is a TestResult.
+func recordExchange(res *testresult.TestResult, ex testresult.Exchange) {
+	if res != nil {
+		res.Exchanges = append(res.Exchanges, ex)
+	}
+}
+
+// checkSent does the rest of the Get, Post, Put or Delete
+// activities, after send has made the call: it records the
+// response body in the TestResult's Got value, and checks the
+// status code.
+func checkSent(res *testresult.TestResult, step string, gotCode int, b []byte, err error, code int) error {
+	if DryRun {
+		planExpectation(res, code)
+		return nil
+	}
+
+	if err != nil {
+		FailTest(res, step, err)
+		return err
+	}
+
+	// record in testresult
+	res.Got = b
+
+	// check expected status code
+	if gotCode != code {
+		err = fmt.Errorf("expected HTTP status code %d, got %d", code, gotCode)
+		FailTest(res, step, err)
+		return err
+	}
+
+	return nil
+}
@@ -0,0 +1,130 @@
+// SPDX-License-Identifier: Apache-2.0 OR GPL-2.0-or-later
+
+package utils
+
+import (
+	"crypto/sha1"
+	"encoding/hex"
+	"fmt"
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+
+	"github.com/swinslow/peridot-jobrunner-testing/internal/testresult"
+	"github.com/swinslow/peridot-jobrunner-testing/test/spdx"
+)
+
+// CheckSPDXDocument parses the SPDX document at path and
+// validates it. On success, it returns the parsed document. On
+// failure, it fills in the failure code in the TestResult and
+// returns an error.
+func CheckSPDXDocument(res *testresult.TestResult, step string, path string) (*spdx.Document, error) {
+	if skipFiles(res, step, "parse and validate the SPDX document %s", path) {
+		return nil, nil
+	}
+
+	doc, err := spdx.ParseFile(path)
+	if err != nil {
+		FailTest(res, step, err)
+		return nil, err
+	}
+
+	errs := spdx.Validate(doc)
+	if errs != nil {
+		msgs := []string{}
+		for _, e := range errs {
+			msgs = append(msgs, e.Error())
+		}
+		err = fmt.Errorf("invalid SPDX document %s: %s", path, strings.Join(msgs, "; "))
+		FailTest(res, step, err)
+		return nil, err
+	}
+
+	return doc, nil
+}
+
+// CheckSPDXPackage checks that doc contains a package with the
+// given name. On failure, it fills in the failure code in the
+// TestResult and returns an error.
+func CheckSPDXPackage(res *testresult.TestResult, step string, doc *spdx.Document, name string) error {
+	if skipFiles(res, step, "check that the SPDX document has package %s", name) {
+		return nil
+	}
+
+	if doc.Package(name) == nil {
+		err := fmt.Errorf("expected SPDX package %q, not found", name)
+		FailTest(res, step, err)
+		return err
+	}
+
+	return nil
+}
+
+// CheckSPDXFileLicense checks that doc contains a file with the
+// given name, whose concluded license is the wanted license
+// identifier or expression. On failure, it fills in the failure
+// code in the TestResult and returns an error.
+func CheckSPDXFileLicense(res *testresult.TestResult, step string, doc *spdx.Document, fileName string, license string) error {
+	if skipFiles(res, step, "check that SPDX file %s has license %s", fileName, license) {
+		return nil
+	}
+
+	f := doc.File(fileName)
+	if f == nil {
+		err := fmt.Errorf("expected SPDX file %q, not found", fileName)
+		FailTest(res, step, err)
+		return err
+	}
+
+	if f.LicenseConcluded != license {
+		err := fmt.Errorf("expected license %q for SPDX file %q, got %q", license, fileName, f.LicenseConcluded)
+		FailTest(res, step, err)
+		return err
+	}
+
+	return nil
+}
+
+// CheckSPDXRelationship checks that doc contains the
+// relationship "refA relType refB". On failure, it fills in the
+// failure code in the TestResult and returns an error.
+func CheckSPDXRelationship(res *testresult.TestResult, step string, doc *spdx.Document, refA string, relType string, refB string) error {
+	if skipFiles(res, step, "check for SPDX relationship %s %s %s", refA, relType, refB) {
+		return nil
+	}
+
+	if !doc.HasRelationship(refA, relType, refB) {
+		err := fmt.Errorf("expected SPDX relationship %s %s %s, not found", refA, relType, refB)
+		FailTest(res, step, err)
+		return err
+	}
+
+	return nil
+}
+
+// CheckSPDXFileChecksums checks that the SHA1 checksum of each
+// file in doc matches the file of the same name under dir, e.g.
+// the repo's directory in the code volume. On failure, it fills
+// in the failure code in the TestResult and returns an error.
+func CheckSPDXFileChecksums(res *testresult.TestResult, step string, doc *spdx.Document, dir string) error {
+	if skipFiles(res, step, "check the SPDX file checksums against the files in %s", dir) {
+		return nil
+	}
+
+	for _, f := range doc.Files {
+		b, err := ioutil.ReadFile(filepath.Join(dir, filepath.FromSlash(f.Name)))
+		if err != nil {
+			FailTest(res, step, err)
+			return err
+		}
+		sum := sha1.Sum(b)
+		got := hex.EncodeToString(sum[:])
+		if got != f.Checksums["SHA1"] {
+			err = fmt.Errorf("expected SHA1 %s for SPDX file %q, got %s", f.Checksums["SHA1"], f.Name, got)
+			FailTest(res, step, err)
+			return err
+		}
+	}
+
+	return nil
+}
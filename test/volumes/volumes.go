@@ -0,0 +1,60 @@
+// SPDX-License-Identifier: Apache-2.0 OR GPL-2.0-or-later
+
+// Package volumes checks the fixture trees that the code and
+// spdx volumes are seeded from, so that a broken fixture is
+// reported as such rather than as a failure of the agents that
+// read the volumes.
+package volumes
+
+import (
+	"github.com/swinslow/peridot-jobrunner-testing/internal/testresult"
+	"github.com/swinslow/peridot-jobrunner-testing/test/utils"
+)
+
+// GetTests returns all of the fixture volume tests.
+func GetTests() []testresult.Test {
+	return []testresult.Test{
+		{Func: spdxSeededDocument, Tags: []string{"readonly", "fixtures"}},
+	}
+}
+
+// ===== seeded spdx volume
+
+func spdxSeededDocument(root string) *testresult.TestResult {
+	res := &testresult.TestResult{
+		Suite:   "fixtures",
+		Element: "spdx",
+		ID:      "seeded document",
+	}
+
+	// the seeded document must be valid SPDX
+	doc, err := utils.CheckSPDXDocument(res, "1", utils.SpdxPath("testrepo.spdx"))
+	if err != nil {
+		return res
+	}
+
+	// and must describe the seeded code volume
+	err = utils.CheckSPDXPackage(res, "2", doc, "testrepo")
+	if err != nil {
+		return res
+	}
+	err = utils.CheckSPDXRelationship(res, "3", doc, "SPDXRef-DOCUMENT", "DESCRIBES", "SPDXRef-Package-testrepo")
+	if err != nil {
+		return res
+	}
+	err = utils.CheckSPDXFileLicense(res, "4", doc, "hello.c", "Apache-2.0")
+	if err != nil {
+		return res
+	}
+	err = utils.CheckSPDXFileLicense(res, "5", doc, "util.py", "MIT")
+	if err != nil {
+		return res
+	}
+	err = utils.CheckSPDXFileChecksums(res, "6", doc, utils.CodePath("testrepo"))
+	if err != nil {
+		return res
+	}
+
+	utils.Pass(res)
+	return res
+}
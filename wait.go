@@ -0,0 +1,60 @@
+// SPDX-License-Identifier: Apache-2.0 OR GPL-2.0-or-later
+
+package main
+
+import (
+	"fmt"
+	"os"
+	"sort"
+	"time"
+
+	"github.com/swinslow/peridot-jobrunner-testing/fixtures"
+	"github.com/swinslow/peridot-jobrunner-testing/internal/ready"
+)
+
+// waitForDependencies waits for postgres, the API, the jobrunner
+// and each agent registered by the fixtures to come up, and
+// prints how long each took. It returns false, after printing
+// which dependencies never came up, if any of them did not.
+// An empty jobrunnerAddr skips the jobrunner.
+func waitForDependencies(apiRoot string, pg *fixtures.PGConfig, jobrunnerAddr string, timeout time.Duration, b ready.Backoff) bool {
+	deps := []ready.Dependency{
+		{
+			Name:  fmt.Sprintf("postgres (%s:%d)", pg.Host, pg.Port),
+			Probe: func() error { return fixtures.PGReady(pg) },
+		},
+		{
+			Name:  fmt.Sprintf("api (%s/health)", apiRoot),
+			Probe: ready.HTTP(apiRoot+"/health", 200),
+		},
+	}
+	if jobrunnerAddr != "" {
+		deps = append(deps, ready.Dependency{
+			Name:  fmt.Sprintf("jobrunner (%s)", jobrunnerAddr),
+			Probe: ready.TCP(jobrunnerAddr),
+		})
+	}
+
+	agents := fixtures.AgentAddrs()
+	names := []string{}
+	for name := range agents {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		deps = append(deps, ready.Dependency{
+			Name:  fmt.Sprintf("agent %s (%s)", name, agents[name]),
+			Probe: ready.TCP(agents[name]),
+		})
+	}
+
+	fmt.Printf("Waiting up to %s for dependencies:\n", timeout)
+	statuses := ready.Wait(deps, timeout, b)
+	ready.Print(os.Stdout, statuses)
+	if !ready.AllReady(statuses) {
+		fmt.Printf("Dependencies did not come up; not running tests.\n")
+		return false
+	}
+	fmt.Printf("\n")
+	return true
+}